@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"rivera/river"
+
+	"github.com/urfave/cli/v2"
+)
+
+// its own binary so bubbletea, which asks the terminal about its colors as soon as its loaded, stays out of rivera
+func main() {
+	app := &cli.App{
+		Name:                   "rivera-tui",
+		Version:                "0.0.1",
+		Usage:                  "browse the river interactively",
+		Description:            "takes the same options as rivera, any left off the command line can come from the [rivera] section of git config",
+		UseShortOptionHandling: true,
+		/// patterns can have commas in them
+		DisableSliceFlagSeparator: true,
+		ArgsUsage:                 "[<revision range>...] [-- <path>...]",
+		Flags:                     river.Flags,
+		Action: func(ctx *cli.Context) error {
+			/// rows show up as theyre walked, reversing needs all of them
+			if err := ctx.Set("reverse", "false"); err != nil {
+				return err
+			}
+			if err := river.ReadConfig(ctx); err != nil {
+				return err
+			}
+			r, err := river.Open(ctx)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			return runTUI(r)
+		},
+	}
+	if err := app.Run(river.ReorderArgs(os.Args)); err != nil {
+		log.Fatal(err)
+	}
+}
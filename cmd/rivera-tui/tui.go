@@ -0,0 +1,601 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"rivera/river"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// how many commits get pulled off the walker at a time
+const tuiChunk = 200
+
+// go-git storage isnt safe to read from two goroutines, and the walker and the diffs both do
+var repoLock sync.Mutex
+
+const (
+	TUI_BROWSE = iota
+	TUI_SEARCH
+	TUI_REF
+)
+
+var (
+	selectedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("3"))
+	statusStyle   = lipgloss.NewStyle().Reverse(true)
+	boldStyle     = lipgloss.NewStyle().Bold(true)
+)
+
+// pulls commits off the walker and draws them a chunk at a time
+// only one load runs at once, so the walker and graph never get touched from two places
+type loader struct {
+	river *river.River
+}
+
+type loadedMsg struct {
+	commits []*object.Commit
+	blocks  [][]string
+	/// which line of each block is the commit row
+	rows []int
+	done bool
+	err  error
+}
+
+type detailMsg struct {
+	hash  plumbing.Hash
+	lines []string
+}
+
+func (L *loader) load(count int) tea.Cmd {
+	return func() tea.Msg {
+		msg := loadedMsg{}
+		for len(msg.commits) < count {
+			c, lines, row, err := L.next()
+			if err == io.EOF {
+				msg.done = true
+				break
+			} else if err != nil {
+				msg.err = err
+				break
+			}
+			msg.commits = append(msg.commits, c)
+			msg.blocks = append(msg.blocks, lines)
+			msg.rows = append(msg.rows, row)
+		}
+		return msg
+	}
+}
+
+func (L *loader) next() (*object.Commit, []string, int, error) {
+	repoLock.Lock()
+	defer repoLock.Unlock()
+	return L.river.Next()
+}
+
+type browser struct {
+	river  *river.River
+	loader *loader
+
+	lines   []string
+	commits []*object.Commit
+	/// the line each commit sits on
+	rows          []int
+	loading, done bool
+	err           error
+
+	cursor, top   int
+	width, height int
+
+	showPanel, panelFocus bool
+	panelTop              int
+	details               map[plumbing.Hash][]string
+
+	mode  int
+	input string
+	/// where the cursor was when a prompt opened, esc goes back there
+	origin int
+	query  string
+	/// keep loading until a commit matches, nil when were not looking for anything
+	seek   func(*object.Commit) bool
+	toEnd  bool
+	status string
+}
+
+// full screen river, commits load as you scroll so big repos dont have to be walked up front
+func runTUI(r *river.River) error {
+	B := &browser{
+		river:     r,
+		loader:    &loader{river: r},
+		showPanel: true,
+		details:   make(map[plumbing.Hash][]string),
+		status:    "loading...",
+	}
+	_, err := tea.NewProgram(B, tea.WithAltScreen(), tea.WithMouseCellMotion()).Run()
+	if err != nil {
+		return err
+	}
+	return B.err
+}
+
+func (B *browser) Init() tea.Cmd {
+	return B.loadMore()
+}
+
+func (B *browser) loadMore() tea.Cmd {
+	if B.loading || B.done {
+		return nil
+	}
+	B.loading = true
+	return B.loader.load(tuiChunk)
+}
+
+func (B *browser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		B.width, B.height = msg.Width, msg.Height
+		return B, B.moveTo(B.cursor)
+	case loadedMsg:
+		return B, B.loaded(msg)
+	case detailMsg:
+		B.details[msg.hash] = msg.lines
+		return B, nil
+	case tea.MouseMsg:
+		switch msg.Button {
+		case tea.MouseButtonWheelDown:
+			return B, B.scroll(3)
+		case tea.MouseButtonWheelUp:
+			return B, B.scroll(-3)
+		}
+		return B, nil
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return B, tea.Quit
+		}
+		if B.mode != TUI_BROWSE {
+			return B, B.prompt(msg)
+		}
+		return B, B.browse(msg)
+	}
+	return B, nil
+}
+
+func (B *browser) loaded(msg loadedMsg) tea.Cmd {
+	B.loading = false
+	B.done = B.done || msg.done
+	if msg.err != nil {
+		B.err = msg.err
+		B.done = true
+		B.status = msg.err.Error()
+	} else if B.status == "loading..." {
+		B.status = ""
+	}
+	first := len(B.commits)
+	for i, c := range msg.commits {
+		B.commits = append(B.commits, c)
+		B.rows = append(B.rows, len(B.lines)+msg.rows[i])
+		B.lines = append(B.lines, msg.blocks[i]...)
+	}
+
+	if B.seek != nil {
+		for i := first; i < len(B.commits); i++ {
+			if B.seek(B.commits[i]) {
+				B.seek = nil
+				B.status = ""
+				return B.moveTo(i)
+			}
+		}
+		if !B.done {
+			return B.loadMore()
+		}
+		B.seek = nil
+		B.status = "nothing found"
+	}
+	if B.toEnd {
+		if !B.done {
+			return B.loadMore()
+		}
+		B.toEnd = false
+		B.status = ""
+		return B.moveTo(len(B.commits) - 1)
+	}
+	return B.moveTo(B.cursor)
+}
+
+func (B *browser) bodyHeight() int {
+	return max(B.height-1, 1)
+}
+
+// selects a commit, scrolls it into view and asks for whatever it needs next
+func (B *browser) moveTo(index int) tea.Cmd {
+	if len(B.commits) == 0 {
+		return nil
+	}
+	index = max(min(index, len(B.commits)-1), 0)
+	if index != B.cursor {
+		B.panelTop = 0
+	}
+	B.cursor = index
+	body := B.bodyHeight()
+	row := B.rows[index]
+	if row < B.top {
+		B.top = row
+	} else if row >= B.top+body {
+		B.top = row - body + 1
+	}
+
+	cmds := make([]tea.Cmd, 0, 2)
+	c := B.commits[index]
+	if _, ok := B.details[c.Hash]; !ok && B.showPanel {
+		/// marks it as asked for, so moving back and forth doesnt ask twice
+		B.details[c.Hash] = nil
+		cmds = append(cmds, commitDetails(B.river, c))
+	}
+	/// stay a couple pages ahead of the cursor
+	if len(B.lines)-row < body*3 {
+		cmds = append(cmds, B.loadMore())
+	}
+	return tea.Batch(cmds...)
+}
+
+func (B *browser) scroll(lines int) tea.Cmd {
+	if B.showPanel && B.panelFocus {
+		B.panelTop = max(B.panelTop+lines, 0)
+		if B.cursor < len(B.commits) {
+			B.panelTop = min(B.panelTop, max(len(B.details[B.commits[B.cursor].Hash])-1, 0))
+		}
+		return nil
+	}
+	if lines > 0 {
+		return B.moveTo(B.cursor + 1)
+	}
+	return B.moveTo(B.cursor - 1)
+}
+
+// moves by a screenful of lines, not commits, since commits can take several
+func (B *browser) page(direction int) tea.Cmd {
+	body := B.bodyHeight()
+	if B.showPanel && B.panelFocus {
+		return B.scroll(direction * body)
+	}
+	if len(B.rows) == 0 {
+		return nil
+	}
+	target := B.rows[B.cursor] + direction*body
+	index := sort.SearchInts(B.rows, target)
+	if direction < 0 && (index == len(B.rows) || B.rows[index] > target) {
+		index--
+	}
+	return B.moveTo(index)
+}
+
+func (B *browser) browse(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "q":
+		return tea.Quit
+	case "j", "down":
+		return B.scroll(1)
+	case "k", "up":
+		return B.scroll(-1)
+	case "pgdown", "ctrl+f", " ":
+		return B.page(1)
+	case "pgup", "ctrl+b":
+		return B.page(-1)
+	case "g", "home":
+		if B.panelFocus {
+			B.panelTop = 0
+			return nil
+		}
+		return B.moveTo(0)
+	case "G", "end":
+		if B.done {
+			return B.moveTo(len(B.commits) - 1)
+		}
+		B.toEnd = true
+		B.status = "loading everything..."
+		return B.loadMore()
+	case "tab":
+		B.panelFocus = B.showPanel && !B.panelFocus
+	case "enter":
+		B.showPanel = !B.showPanel
+		B.panelFocus = false
+		return B.moveTo(B.cursor)
+	case "/":
+		B.mode, B.input, B.origin = TUI_SEARCH, "", B.cursor
+	case "r":
+		B.mode, B.input, B.origin = TUI_REF, "", B.cursor
+	case "n", "N":
+		if B.query == "" {
+			return nil
+		}
+		if msg.String() == "N" {
+			return B.find(searchMatcher(B.query), B.cursor-1, -1)
+		}
+		return B.find(searchMatcher(B.query), B.cursor+1, 1)
+	case "esc":
+		B.seek, B.toEnd = nil, false
+		B.status = ""
+	}
+	return nil
+}
+
+// typing into the search or ref prompt, jumps around as you type
+func (B *browser) prompt(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc:
+		B.mode = TUI_BROWSE
+		B.seek = nil
+		B.status = ""
+		return B.moveTo(B.origin)
+	case tea.KeyEnter:
+		mode := B.mode
+		B.mode = TUI_BROWSE
+		if B.input == "" {
+			return B.moveTo(B.origin)
+		}
+		if mode == TUI_SEARCH {
+			B.query = B.input
+			return B.find(searchMatcher(B.query), B.origin, 1)
+		}
+		return B.findRef(B.input)
+	case tea.KeyBackspace:
+		if B.input == "" {
+			B.mode = TUI_BROWSE
+			return B.moveTo(B.origin)
+		}
+		runes := []rune(B.input)
+		B.input = string(runes[:len(runes)-1])
+	case tea.KeyRunes:
+		B.input += string(msg.Runes)
+	case tea.KeySpace:
+		B.input += " "
+	default:
+		return nil
+	}
+
+	/// only whats loaded so far, enter keeps looking
+	match := searchMatcher(B.input)
+	from := B.origin
+	if B.mode == TUI_REF {
+		match = refMatcher(B.river, B.input)
+		from = 0
+	}
+	for i := from; i < len(B.commits); i++ {
+		if B.input != "" && match(B.commits[i]) {
+			B.status = ""
+			return B.moveTo(i)
+		}
+	}
+	B.status = "not loaded yet, enter keeps looking"
+	return B.moveTo(B.origin)
+}
+
+// jumps to the next match going in direction, loading more when going down runs out
+func (B *browser) find(match func(*object.Commit) bool, from, direction int) tea.Cmd {
+	for i := from; i >= 0 && i < len(B.commits); i += direction {
+		if match(B.commits[i]) {
+			B.status = ""
+			return B.moveTo(i)
+		}
+	}
+	if direction > 0 && !B.done {
+		B.seek = match
+		B.status = "searching..."
+		return B.loadMore()
+	}
+	B.status = "nothing found"
+	return nil
+}
+
+// anything git can resolve, falls back to matching the shown ref names
+func (B *browser) findRef(name string) tea.Cmd {
+	if name == "" {
+		return nil
+	}
+	if hash, err := B.river.Resolve(name); err == nil {
+		return B.find(func(c *object.Commit) bool {
+			return c.Hash == hash
+		}, 0, 1)
+	}
+	return B.find(refMatcher(B.river, name), 0, 1)
+}
+
+func searchMatcher(query string) func(*object.Commit) bool {
+	query = strings.ToLower(query)
+	return func(c *object.Commit) bool {
+		if query == "" {
+			return false
+		}
+		return strings.HasPrefix(c.Hash.String(), query) ||
+			strings.Contains(strings.ToLower(c.Message), query) ||
+			strings.Contains(strings.ToLower(c.Author.Name), query) ||
+			strings.Contains(strings.ToLower(c.Author.Email), query)
+	}
+}
+
+func refMatcher(r *river.River, name string) func(*object.Commit) bool {
+	return func(c *object.Commit) bool {
+		for _, ref := range r.Refs(c.Hash) {
+			if strings.Contains(ref, name) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (B *browser) View() string {
+	if B.width == 0 {
+		return ""
+	}
+	body := B.bodyHeight()
+	riverWidth, panelWidth := B.width, 0
+	var panel []string
+	separator := lipgloss.NewStyle().Faint(!B.panelFocus).Render("│")
+	if B.showPanel {
+		riverWidth = B.width * 3 / 5
+		panelWidth = B.width - riverWidth - 1
+		if B.cursor < len(B.commits) {
+			panel = B.details[B.commits[B.cursor].Hash]
+		}
+		if panel == nil {
+			panel = []string{"loading..."}
+		}
+	}
+
+	view := strings.Builder{}
+	for i := 0; i < body; i++ {
+		line := ""
+		if n := B.top + i; n < len(B.lines) {
+			gutter := "  "
+			if B.cursor < len(B.rows) && B.rows[B.cursor] == n {
+				gutter = selectedStyle.Render("> ")
+			}
+			line = ansi.Truncate(gutter+B.lines[n], riverWidth, "")
+		}
+		if B.showPanel {
+			line += strings.Repeat(" ", max(riverWidth-ansi.StringWidth(line), 0)) + separator
+			if n := B.panelTop + i; n < len(panel) {
+				line += ansi.Truncate(panel[n], panelWidth, "")
+			}
+		}
+		view.WriteString(line + "\n")
+	}
+	view.WriteString(B.statusLine())
+	return view.String()
+}
+
+func (B *browser) statusLine() string {
+	left := B.status
+	switch B.mode {
+	case TUI_SEARCH:
+		left = "/" + B.input
+	case TUI_REF:
+		left = "ref: " + B.input
+	}
+	right := "0/0"
+	if len(B.commits) > 0 {
+		right = fmt.Sprintf("%d/%d", B.cursor+1, len(B.commits))
+	}
+	if !B.done {
+		right += "+"
+	}
+	right += "  q quit, / search, r ref, tab panel, enter hide panel "
+	gap := max(B.width-ansi.StringWidth(left)-ansi.StringWidth(right), 1)
+	return statusStyle.Render(ansi.Truncate(left+strings.Repeat(" ", gap)+right, B.width, ""))
+}
+
+// the message, files and diff for the side panel, worked out off the main loop
+// since big commits take a while to diff
+func commitDetails(r *river.River, c *object.Commit) tea.Cmd {
+	return func() tea.Msg {
+		repoLock.Lock()
+		defer repoLock.Unlock()
+		return detailMsg{hash: c.Hash, lines: describeCommit(r, c)}
+	}
+}
+
+func describeCommit(r *river.River, c *object.Commit) []string {
+	lines := []string{colorize("commit "+c.Hash.String(), "3") + r.Placeholder("d", c)}
+	if len(c.ParentHashes) > 1 {
+		lines = append(lines, "Merge:  "+r.Placeholder("p", c))
+	}
+	lines = append(lines,
+		fmt.Sprintf("Author: %s <%s>", c.Author.Name, c.Author.Email),
+		"Date:   "+r.Placeholder("ad", c),
+	)
+	if c.Committer.Name != c.Author.Name || c.Committer.Email != c.Author.Email {
+		lines = append(lines, fmt.Sprintf("Commit: %s <%s>", c.Committer.Name, c.Committer.Email))
+	}
+	lines = append(lines, "")
+	for _, line := range strings.Split(strings.TrimRight(c.Message, "\n"), "\n") {
+		lines = append(lines, "    "+expandTabs(line))
+	}
+
+	patch, err := commitPatch(c)
+	if err != nil {
+		return append(lines, "", colorize(err.Error(), "1"))
+	}
+	stats := patch.Stats()
+	if len(stats) == 0 {
+		return lines
+	}
+	lines = append(lines, "")
+	nameWidth := 0
+	for _, stat := range stats {
+		nameWidth = max(nameWidth, len(stat.Name))
+	}
+	for _, stat := range stats {
+		lines = append(lines, fmt.Sprintf(" %-*s | %s %s", nameWidth, stat.Name,
+			colorize(fmt.Sprintf("+%d", stat.Addition), "2"),
+			colorize(fmt.Sprintf("-%d", stat.Deletion), "1")))
+	}
+	if len(c.ParentHashes) > 1 {
+		lines = append(lines, "", "against the first parent")
+	}
+	lines = append(lines, "")
+	for _, line := range strings.Split(strings.TrimRight(patch.String(), "\n"), "\n") {
+		lines = append(lines, diffLine(expandTabs(line)))
+	}
+	return lines
+}
+
+// the changes from the first parent, or everything for a root commit
+func commitPatch(c *object.Commit) (*object.Patch, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var parentTree *object.Tree
+	if c.NumParents() > 0 {
+		parent, err := c.Parent(0)
+		/// the edge of a shallow clone, everything shows up as added like it does for a root
+		switch {
+		case errors.Is(err, plumbing.ErrObjectNotFound):
+		case err != nil:
+			return nil, err
+		default:
+			if parentTree, err = parent.Tree(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return nil, err
+	}
+	return changes.Patch()
+}
+
+func diffLine(line string) string {
+	switch {
+	case strings.HasPrefix(line, "diff --git"), strings.HasPrefix(line, "index "),
+		strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "),
+		strings.HasPrefix(line, "new file"), strings.HasPrefix(line, "deleted file"):
+		return boldStyle.Render(line)
+	case strings.HasPrefix(line, "@@"):
+		return colorize(line, "6")
+	case strings.HasPrefix(line, "+"):
+		return colorize(line, "2")
+	case strings.HasPrefix(line, "-"):
+		return colorize(line, "1")
+	}
+	return line
+}
+
+// tabs would throw off the width math
+func expandTabs(line string) string {
+	return strings.ReplaceAll(line, "\t", "    ")
+}
+
+func colorize(text, color string) string {
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(text)
+}
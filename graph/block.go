@@ -0,0 +1,101 @@
+package graph
+
+import "slices"
+
+// all the rows of one commit
+type Block struct {
+	Rows []Row
+	/// which of Rows is the commit row
+	Commit int
+	/// the lanes running past the commit, for when the text next to it needs more rows than the river does
+	/// they go after Rows
+	Padding Row
+}
+
+// hands every row of the block to R
+func (B Block) Render(R Renderer) error {
+	for _, row := range B.Rows {
+		if err := R.RenderRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// for drawing the river oldest first
+// blocks come out upside down with everything that leans or hangs flipped over,
+// so drawing them last one first puts the lanes back together
+func (G *Graph) SetReverse(reverse bool) {
+	G.reverse = reverse
+}
+
+// lays out node and hands back all of its rows at once
+func (G *Graph) Block(node Node) Block {
+	/// upside down, whatever comes down into the commit is whats left under it
+	var above Row
+	if G.reverse && G.IsCommitFinished() {
+		above = G.NextRow()
+		above.Node = node
+	}
+	G.Update(node)
+	block := Block{}
+	for !G.IsCommitFinished() {
+		row := G.NextRow()
+		if row.Kind == GRAPH_COMMIT {
+			block.Commit = len(block.Rows)
+		}
+		block.Rows = append(block.Rows, row)
+	}
+	if !G.reverse {
+		block.Padding = G.NextRow()
+		return block
+	}
+	slices.Reverse(block.Rows)
+	for i, row := range block.Rows {
+		block.Rows[i] = G.flip(row)
+	}
+	block.Commit = len(block.Rows) - 1 - block.Commit
+	/// it was laid out for the last commit, the text has to line up with this one
+	above.Width = G.width
+	block.Padding = above
+	return block
+}
+
+// the row upside down, the cells stay where they are
+func (G *Graph) flip(row Row) Row {
+	cells := make([]Cell, len(row.Cells))
+	for i, cell := range row.Cells {
+		cell.Kind = cell.Kind.flipped()
+		if glyph, ok := G.glyphs[cell.Kind]; ok {
+			cell.Glyph = glyph
+		} else {
+			cell.Glyph = cell.Kind.ASCII()
+		}
+		cells[i] = cell
+	}
+	row.Cells = cells
+	return row
+}
+
+// what the kind looks like upside down
+func (K GlyphKind) flipped() GlyphKind {
+	switch K {
+	case GLYPH_RMOVE:
+		return GLYPH_LMOVE
+	case GLYPH_LMOVE:
+		return GLYPH_RMOVE
+	case GLYPH_BRIDGE:
+		return GLYPH_BRIDGE_OVER
+	case GLYPH_BRIDGE_OVER:
+		return GLYPH_BRIDGE
+	case GLYPH_MULTIBRANCH_EXTENSION:
+		return GLYPH_MULTIBRANCH_EXTENSION_UP
+	case GLYPH_MULTIBRANCH_EXTENSION_UP:
+		return GLYPH_MULTIBRANCH_EXTENSION
+	case GLYPH_MULTIBRANCH_START:
+		return GLYPH_MULTIBRANCH_START_UP
+	case GLYPH_MULTIBRANCH_START_UP:
+		return GLYPH_MULTIBRANCH_START
+	}
+	return K
+}
@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// the kinds a glyph set can change, one per kind of commit
+// the lanes between them stay ascii
+var commitKinds = []GlyphKind{GLYPH_COMMIT, GLYPH_MERGE, GLYPH_ROOT, GLYPH_HEAD, GLYPH_BOUNDARY, GLYPH_UNREACHABLE}
+
+// named glyph sets for SetGlyphs
+// ascii is what git draws, plus the R for roots rivera always had, the others are opt-in
+var GlyphPresets = map[string]map[GlyphKind]string{
+	"ascii": {
+		GLYPH_COMMIT:      GRAPH_PRINT_COMMIT,
+		GLYPH_MERGE:       GRAPH_PRINT_COMMIT,
+		GLYPH_ROOT:        GRAPH_PRINT_ROOT,
+		GLYPH_HEAD:        GRAPH_PRINT_COMMIT,
+		GLYPH_BOUNDARY:    GRAPH_PRINT_BOUNDARY,
+		GLYPH_UNREACHABLE: GRAPH_PRINT_COMMIT,
+	},
+	"marks": {
+		GLYPH_COMMIT:      GRAPH_PRINT_COMMIT,
+		GLYPH_MERGE:       GRAPH_PRINT_MERGE,
+		GLYPH_ROOT:        GRAPH_PRINT_ROOT,
+		GLYPH_HEAD:        GRAPH_PRINT_HEAD,
+		GLYPH_BOUNDARY:    GRAPH_PRINT_BOUNDARY,
+		GLYPH_UNREACHABLE: GRAPH_PRINT_UNREACHABLE,
+	},
+	"unicode": {
+		GLYPH_COMMIT:      "●",
+		GLYPH_MERGE:       "◆",
+		GLYPH_ROOT:        "■",
+		GLYPH_HEAD:        "◉",
+		GLYPH_BOUNDARY:    "◌",
+		GLYPH_UNREACHABLE: "○",
+	},
+}
+
+// comma separated presets and kind=glyph overrides, applied in order
+// like "unicode" or "unicode,head=@,root=R"
+func (G *Graph) SetGlyphs(spec string) error {
+	glyphs := make(map[GlyphKind]string)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, glyph, isOverride := strings.Cut(item, "=")
+		if !isOverride {
+			preset, ok := GlyphPresets[name]
+			if !ok {
+				return fmt.Errorf("unknown glyph preset '%s', want ascii, marks or unicode", name)
+			}
+			maps.Copy(glyphs, preset)
+			continue
+		}
+		i := slices.IndexFunc(commitKinds, func(kind GlyphKind) bool { return kind.String() == name })
+		if i < 0 {
+			return fmt.Errorf("unknown glyph kind '%s', want commit, merge, root, head, boundary or unreachable", name)
+		}
+		/// the columns are counted in characters
+		if utf8.RuneCountInString(glyph) != 1 {
+			return fmt.Errorf("glyph for %s should be one character, got '%s'", name, glyph)
+		}
+		glyphs[commitKinds[i]] = glyph
+	}
+	G.glyphSet = glyphs
+	G.updateGlyphs()
+	return nil
+}
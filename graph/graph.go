@@ -1,10 +1,15 @@
+// lays out commits the way `git log --graph` does
+// rows come out as cells with a glyph kind, a column, a color index and the commit the lane leads to,
+// so anything can draw them, see Renderer and TextRenderer
 package graph
 
 import (
+	"maps"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
-	"github.com/charmbracelet/lipgloss"
-	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing"
 )
 
 /// port of gits graph.c
@@ -12,7 +17,7 @@ import (
 /// see it for detailed docs, this is just rough and dirty
 
 const (
-	GRAPH_PADDING = iota
+	GRAPH_PADDING GraphState = iota
 	GRAPH_SKIP
 	GRAPH_PRE_COMMIT
 	GRAPH_COMMIT
@@ -22,36 +27,239 @@ const (
 const (
 	GRAPH_PRINT_MULTIBRANCH_EXTENSION = "-"
 	GRAPH_PRINT_MULTIBRANCH_START     = "."
+	GRAPH_PRINT_MULTIBRANCH_START_UP  = "'"
 	GRAPH_PRINT_BRIDGE                = "_"
+	GRAPH_PRINT_BRIDGE_OVER           = "‾"
 	GRAPH_PRINT_PADDING               = "|"
 	GRAPH_PRINT_COMMIT                = "*"
+	GRAPH_PRINT_ROOT                  = "R"
+	GRAPH_PRINT_BOUNDARY              = "o"
+	GRAPH_PRINT_MERGE                 = "M"
+	GRAPH_PRINT_HEAD                  = "@"
+	GRAPH_PRINT_UNREACHABLE           = "+"
 	GRAPH_PRINT_RMOVE                 = "\\"
 	GRAPH_PRINT_LMOVE                 = "/"
 )
 
-var mergeChars = []string{GRAPH_PRINT_LMOVE, GRAPH_PRINT_PADDING, GRAPH_PRINT_RMOVE}
+// what a cell is, renderers pick how to draw each kind
+type GlyphKind int
+
+const (
+	GLYPH_PADDING GlyphKind = iota
+	GLYPH_COMMIT
+	GLYPH_ROOT
+	GLYPH_RMOVE
+	GLYPH_LMOVE
+	GLYPH_BRIDGE
+	GLYPH_MULTIBRANCH_EXTENSION
+	GLYPH_MULTIBRANCH_START
+	GLYPH_SKIP
+	GLYPH_BOUNDARY
+	GLYPH_MERGE
+	GLYPH_HEAD
+	GLYPH_UNREACHABLE
+	/// the upside down ones, see SetReverse
+	GLYPH_BRIDGE_OVER
+	GLYPH_MULTIBRANCH_EXTENSION_UP
+	GLYPH_MULTIBRANCH_START_UP
+)
+
+func (K GlyphKind) String() string {
+	switch K {
+	case GLYPH_PADDING:
+		return "padding"
+	case GLYPH_COMMIT:
+		return "commit"
+	case GLYPH_ROOT:
+		return "root"
+	case GLYPH_RMOVE:
+		return "rmove"
+	case GLYPH_LMOVE:
+		return "lmove"
+	case GLYPH_BRIDGE:
+		return "bridge"
+	case GLYPH_MULTIBRANCH_EXTENSION:
+		return "multibranch-extension"
+	case GLYPH_MULTIBRANCH_START:
+		return "multibranch-start"
+	case GLYPH_SKIP:
+		return "skip"
+	case GLYPH_BOUNDARY:
+		return "boundary"
+	case GLYPH_MERGE:
+		return "merge"
+	case GLYPH_HEAD:
+		return "head"
+	case GLYPH_UNREACHABLE:
+		return "unreachable"
+	case GLYPH_BRIDGE_OVER:
+		return "bridge-over"
+	case GLYPH_MULTIBRANCH_EXTENSION_UP:
+		return "multibranch-extension-up"
+	case GLYPH_MULTIBRANCH_START_UP:
+		return "multibranch-start-up"
+	}
+	return "unknown"
+}
+
+// the characters git draws
+func (K GlyphKind) ASCII() string {
+	switch K {
+	case GLYPH_PADDING:
+		return GRAPH_PRINT_PADDING
+	case GLYPH_COMMIT:
+		return GRAPH_PRINT_COMMIT
+	case GLYPH_ROOT:
+		return GRAPH_PRINT_ROOT
+	case GLYPH_RMOVE:
+		return GRAPH_PRINT_RMOVE
+	case GLYPH_LMOVE:
+		return GRAPH_PRINT_LMOVE
+	case GLYPH_BRIDGE:
+		return GRAPH_PRINT_BRIDGE
+	case GLYPH_MULTIBRANCH_EXTENSION:
+		return GRAPH_PRINT_MULTIBRANCH_EXTENSION
+	case GLYPH_MULTIBRANCH_START:
+		return GRAPH_PRINT_MULTIBRANCH_START
+	case GLYPH_SKIP:
+		return "..."
+	case GLYPH_BOUNDARY:
+		return GRAPH_PRINT_BOUNDARY
+	case GLYPH_MERGE, GLYPH_HEAD, GLYPH_UNREACHABLE:
+		/// git draws these like any other commit, "marks" tells them apart
+		return GRAPH_PRINT_COMMIT
+	case GLYPH_BRIDGE_OVER:
+		return GRAPH_PRINT_BRIDGE_OVER
+	case GLYPH_MULTIBRANCH_EXTENSION_UP:
+		return GRAPH_PRINT_MULTIBRANCH_EXTENSION
+	case GLYPH_MULTIBRANCH_START_UP:
+		return GRAPH_PRINT_MULTIBRANCH_START_UP
+	}
+	return "?"
+}
+
+// true for the cell that is the commit itself
+func (K GlyphKind) IsCommit() bool {
+	return slices.Contains(commitKinds, K)
+}
+
+var mergeChars = []GlyphKind{GLYPH_LMOVE, GLYPH_PADDING, GLYPH_RMOVE}
+
+// all the graph needs to know about a commit
+// commitgraph.CommitNode fits, so does anything else that can hand out hashes
+type Node interface {
+	ID() plumbing.Hash
+	ParentHashes() []plumbing.Hash
+}
+
+// nodes whose parents got rewritten, like a path limited walk does, can say how many the commit really has
+// the root and merge glyphs go by that, the lanes still follow ParentHashes
+type Rewritten interface {
+	OriginalParents() int
+}
 
 type Graph struct {
-	commit           *object.Commit
+	node             Node
 	state, prevState GraphState
-	/// git ignores "uninteresting" parents but i dont think thats important here
-	/// never seen an empty commit
+	/// only the interesting parents, same as gits first_interesting_parent loops
+	parents []plumbing.Hash
+	/// nil means everything is interesting, nil boundary means nothing is one
+	interesting, boundary func(plumbing.Hash) bool
+	/// merges only get a lane to their first parent
+	firstParent bool
+	/// blocks come out upside down
+	reverse bool
+	/// zero when theres no HEAD to mark, nil unreachable means nothing is
+	head        plumbing.Hash
+	unreachable func(plumbing.Hash) bool
+	/// kind -> glyph, the ones missing are Kind.ASCII()
+	/// glyphSet is what SetGlyphs got, glyphs is that plus the first parent M
+	glyphSet, glyphs                                  map[GlyphKind]string
+	theme                                             *Theme
 	numParents, edgesAdded, prevEdgesAdded            int
 	width, expansionRow, commitIndex, prevCommitIndex int
 	columns, newColumns                               []*Column
-	mapping, oldMapping                               []int
-	defaultColorIndex, maxColorIndex                  int
-	colors                                            []string
-	columnCapacity                                    int
-	numColumns                                        int
-	numNewColumns                                     int
-	mappingSize, mergeLayout                          int
+	/// which column each commit is in, so lookups dont scan every lane
+	columnIndex, newColumnIndex      map[plumbing.Hash]int
+	mapping, oldMapping              []int
+	defaultColorIndex, maxColorIndex int
+	colors                           []string
+	/// for Line
+	text                     *TextRenderer
+	columnCapacity           int
+	numColumns               int
+	numNewColumns            int
+	mappingSize, mergeLayout int
 }
 type GraphState int
 
+func (S GraphState) String() string {
+	switch S {
+	case GRAPH_PADDING:
+		return "padding"
+	case GRAPH_SKIP:
+		return "skip"
+	case GRAPH_PRE_COMMIT:
+		return "pre-commit"
+	case GRAPH_COMMIT:
+		return "commit"
+	case GRAPH_POST_MERGE:
+		return "post-merge"
+	case GRAPH_COLLAPSING:
+		return "collapsing"
+	}
+	return "unknown"
+}
+
+// one row of the river, straight from the state machine
+type Row struct {
+	/// which state drew it
+	Kind GraphState
+	/// the commit being laid out, the row doesnt have to be its commit line
+	Node  Node
+	Cells []Cell
+	/// in characters, cells dont cover the spaces
+	Width int
+}
+type Cell struct {
+	Kind GlyphKind
+	/// what gets drawn, from the glyph set, see SetGlyphs
+	Glyph string
+	/// character offset from the start of the row
+	Column int
+	/// index into the branch colors, -1 when uncolored
+	Color int
+	/// the commit this lane is headed for, zero for skip cells
+	Commit plumbing.Hash
+}
+
+// anything that draws rows, text, svg, json...
+type Renderer interface {
+	RenderRow(row Row) error
+}
+
+// like gits struct graph_line
+type GraphLine struct {
+	cells  []Cell
+	width  int
+	glyphs map[GlyphKind]string
+}
+
+func (L *GraphLine) addSpaces(n int) {
+	L.width += n
+}
+func (L *GraphLine) addGlyph(kind GlyphKind, color int, commit plumbing.Hash) {
+	glyph, ok := L.glyphs[kind]
+	if !ok {
+		glyph = kind.ASCII()
+	}
+	L.cells = append(L.cells, Cell{Kind: kind, Glyph: glyph, Column: L.width, Color: color, Commit: commit})
+	L.width += utf8.RuneCountInString(glyph)
+}
+
 func New() *Graph {
 	G := &Graph{}
-	G.commit = nil
+	G.node = nil
 	G.state = GRAPH_PADDING
 	G.prevState = GRAPH_PADDING
 	G.defaultColorIndex = G.maxColorIndex
@@ -61,15 +269,26 @@ func New() *Graph {
 	G.newColumns = make([]*Column, G.columnCapacity)
 	G.mapping = make([]int, G.columnCapacity*2)
 	G.oldMapping = make([]int, G.columnCapacity*2)
+	G.columnIndex = make(map[plumbing.Hash]int, G.columnCapacity)
+	G.newColumnIndex = make(map[plumbing.Hash]int, G.columnCapacity)
 
 	return G
 }
 func (G *Graph) IsCommitFinished() bool {
 	return G.state == GRAPH_PADDING
 }
-func (G *Graph) NextLine() (string, bool) {
-	graphLine := "" //GraphLine{width: 0}
-	commitLine := false
+
+// the row drawn as text with the graphs colors
+func (G *Graph) Line(row Row) string {
+	if G.text == nil {
+		G.text = &TextRenderer{Colors: G.colors, Theme: G.theme}
+	}
+	return G.text.Line(row)
+}
+
+func (G *Graph) NextRow() Row {
+	graphLine := GraphLine{glyphs: G.glyphs}
+	row := Row{Kind: G.state, Node: G.node}
 	switch G.state {
 	case GRAPH_PADDING:
 		G.outputPaddingLine(&graphLine)
@@ -79,19 +298,33 @@ func (G *Graph) NextLine() (string, bool) {
 		G.outputPreCommitLine(&graphLine)
 	case GRAPH_COMMIT:
 		G.outputCommitLine(&graphLine)
-		commitLine = true
 	case GRAPH_POST_MERGE:
 		G.outputPostMergeLine(&graphLine)
 	case GRAPH_COLLAPSING:
 		G.outputCollapsingLine(&graphLine)
 	}
 	G.padHorizontally(&graphLine)
-	return graphLine, commitLine
+	row.Cells = graphLine.cells
+	row.Width = graphLine.width
+	return row
 }
-func (G *Graph) Update(commit *object.Commit) {
-	G.commit = commit
-	/// maybe: implement interest
-	G.numParents = len(commit.ParentHashes)
+
+// lays out the next commit, only its hashes get looked at
+func (G *Graph) Update(node Node) {
+	G.node = node
+	G.parents = G.parents[:0]
+	/// boundary commits are where the walk stopped, nothing below them gets shown so no lanes lead down from them
+	isBoundary := G.boundary != nil && G.boundary(node.ID())
+	for i, hash := range node.ParentHashes() {
+		/// like gits first_interesting_parent, an uninteresting first parent means none at all
+		if isBoundary || (G.firstParent && i > 0) {
+			break
+		}
+		if G.isInteresting(hash) {
+			G.parents = append(G.parents, hash)
+		}
+	}
+	G.numParents = len(G.parents)
 	G.prevCommitIndex = G.commitIndex
 
 	G.updateColumns()
@@ -106,17 +339,80 @@ func (G *Graph) Update(commit *object.Commit) {
 	}
 }
 
+// edges are only drawn to parents the predicate accepts,
+// use it when the walk skips commits so their lanes dont dangle
+func (G *Graph) SetInterest(interesting func(hash plumbing.Hash) bool) {
+	G.interesting = interesting
+}
+
+// commits the predicate accepts get drawn with an o like git --boundary does
+// theyre edges out of what gets shown, so the interest predicate should keep lanes to them,
+// they dont get lanes down to their own parents
+func (G *Graph) SetBoundary(boundary func(hash plumbing.Hash) bool) {
+	G.boundary = boundary
+}
+
+// like git --first-parent, lanes only ever lead to the first parent
+// merges still hand over all their parents, so they still get the merge glyph,
+// an M when the glyph set draws merges like any other commit
+func (G *Graph) SetFirstParent(firstParent bool) {
+	G.firstParent = firstParent
+	G.updateGlyphs()
+}
+
+func (G *Graph) updateGlyphs() {
+	G.glyphs = G.glyphSet
+	if !G.firstParent || G.glyphFor(GLYPH_MERGE) != G.glyphFor(GLYPH_COMMIT) {
+		return
+	}
+	G.glyphs = maps.Clone(G.glyphSet)
+	if G.glyphs == nil {
+		G.glyphs = make(map[GlyphKind]string)
+	}
+	G.glyphs[GLYPH_MERGE] = GRAPH_PRINT_MERGE
+}
+
+func (G *Graph) glyphFor(kind GlyphKind) string {
+	if glyph, ok := G.glyphSet[kind]; ok {
+		return glyph
+	}
+	return kind.ASCII()
+}
+
+// the commit that gets the head glyph
+func (G *Graph) SetHead(head plumbing.Hash) {
+	G.head = head
+}
+
+// commits the predicate accepts get the unreachable glyph, meant for the ones HEAD cant get to with --all
+func (G *Graph) SetUnreachable(unreachable func(hash plumbing.Hash) bool) {
+	G.unreachable = unreachable
+}
+func (G *Graph) isInteresting(hash plumbing.Hash) bool {
+	return G.interesting == nil || G.interesting(hash)
+}
+
 // / comma separated colors
 func (G *Graph) SetColors(colorstring string) {
 	colors := strings.Split(colorstring, ",")
-	for _, color := range colors {
-		color = strings.Trim(color, " ")
+	for i, color := range colors {
+		colors[i] = strings.Trim(color, " ")
 	}
 	G.maxColorIndex = len(colors)
 	if G.maxColorIndex < 2 {
 		panic("too few colors, need 2 minimum")
 	}
 	G.colors = colors
+	G.text = nil
+}
+
+// how Line draws the lanes, nil for plain ascii
+func (G *Graph) SetTheme(theme *Theme) {
+	G.theme = theme
+	G.text = nil
+}
+func (G *Graph) Colors() []string {
+	return G.colors
 }
 func (G *Graph) updateColumns() {
 	isCommitInColumns := false
@@ -124,6 +420,10 @@ func (G *Graph) updateColumns() {
 	tempCols := G.columns
 	G.columns = G.newColumns
 	G.newColumns = tempCols
+	tempIndex := G.columnIndex
+	G.columnIndex = G.newColumnIndex
+	G.newColumnIndex = tempIndex
+	clear(G.newColumnIndex)
 
 	G.numColumns = G.numNewColumns
 	G.numNewColumns = 0
@@ -140,25 +440,22 @@ func (G *Graph) updateColumns() {
 	seenThis := false
 	isCommitInColumns = true
 	for i := 0; i <= G.numColumns; i++ {
-		var colCommit *object.Commit
+		var colCommit plumbing.Hash
 		if i == G.numColumns {
 			if seenThis {
 				break
 			}
 			isCommitInColumns = false
-			colCommit = G.commit
+			colCommit = G.node.ID()
 		} else {
 			colCommit = G.columns[i].commit
 		}
 
-		if colCommit.Hash.String() == G.commit.Hash.String() {
+		if colCommit == G.node.ID() {
 			seenThis = true
 			G.commitIndex = i
 			G.mergeLayout = -1
-			/// maybe: implement interest
-
-			for parentIdx := range G.commit.ParentHashes {
-				parent, _ := G.commit.Parent(parentIdx)
+			for _, parent := range G.parents {
 				if G.numParents > 1 || !isCommitInColumns {
 					G.incrementColumnColor()
 				}
@@ -233,7 +530,7 @@ func (G *Graph) isMappingCorrect() bool {
 	}
 	return true
 }
-func (G *Graph) insertIntoNewColumns(commit *object.Commit, idx int) {
+func (G *Graph) insertIntoNewColumns(commit plumbing.Hash, idx int) {
 	i := G.findNewColumnByCommit(commit)
 	var mappingIndex int
 	if i < 0 {
@@ -243,6 +540,7 @@ func (G *Graph) insertIntoNewColumns(commit *object.Commit, idx int) {
 			commit: commit,
 			color:  G.findCommitColor(commit),
 		}
+		G.newColumnIndex[commit] = i
 	}
 	if G.numParents > 1 && idx > -1 && G.mergeLayout == -1 {
 		dist := idx - i
@@ -266,18 +564,27 @@ func (G *Graph) insertIntoNewColumns(commit *object.Commit, idx int) {
 	}
 	G.mapping[mappingIndex] = i
 }
-func (G *Graph) findNewColumnByCommit(commit *object.Commit) int {
-	for i := 0; i < G.numNewColumns; i++ {
-		if G.newColumns[i].commit.Hash.String() == commit.Hash.String() {
-			return i
-		}
+func (G *Graph) findNewColumnByCommit(commit plumbing.Hash) int {
+	if i, ok := G.newColumnIndex[commit]; ok {
+		return i
 	}
 	return -1
 }
-func (G *Graph) findCommitColor(commit *object.Commit) int {
-	for i := 0; i < G.numColumns; i++ {
-		if G.columns[i].commit.Hash.String() == commit.Hash.String() {
-			return G.columns[i].color
+func (G *Graph) findCommitColor(commit plumbing.Hash) int {
+	if i, ok := G.columnIndex[commit]; ok {
+		return G.columns[i].color
+	}
+	return G.getCurrentColumnColor()
+}
+
+// the lane the commit sits in, the one coming from above if theres one
+func (G *Graph) commitColor() int {
+	if G.commitIndex < G.numColumns {
+		return G.columns[G.commitIndex].color
+	}
+	if G.numParents > 0 {
+		if i := G.findNewColumnByCommit(G.parents[0]); i > -1 {
+			return G.newColumns[i].color
 		}
 	}
 	return G.getCurrentColumnColor()
@@ -290,40 +597,39 @@ func (G *Graph) updateState(newState GraphState) {
 	G.state = newState
 }
 
-func (G *Graph) drawOctopusMerge(line *string) {
+func (G *Graph) drawOctopusMerge(line *GraphLine) {
 	dashedParents := G.numDashedParents()
 
 	for i := 0; i < dashedParents; i++ {
 		j := G.mapping[(G.commitIndex+i+2)*2]
 		column := G.newColumns[j]
 
-		G.lineWriteColumn(line, column, GRAPH_PRINT_MULTIBRANCH_EXTENSION)
+		G.lineWriteColumn(line, column, GLYPH_MULTIBRANCH_EXTENSION)
 		if i == dashedParents-1 {
-			G.lineWriteColumn(line, column, GRAPH_PRINT_MULTIBRANCH_START)
+			G.lineWriteColumn(line, column, GLYPH_MULTIBRANCH_START)
 		} else {
-			G.lineWriteColumn(line, column, GRAPH_PRINT_MULTIBRANCH_EXTENSION)
+			G.lineWriteColumn(line, column, GLYPH_MULTIBRANCH_EXTENSION)
 		}
 	}
 }
 
-func (G *Graph) padHorizontally(line *string) {
-	lineWidth := len(*line)
-	if lineWidth < G.width {
-		*line += strings.Repeat(" ", G.width-lineWidth)
+func (G *Graph) padHorizontally(line *GraphLine) {
+	if line.width < G.width {
+		line.addSpaces(G.width - line.width)
 	}
 }
-func (G *Graph) lineWriteColumn(line *string, column *Column, char string) {
-	*line += lipgloss.NewStyle().Foreground(lipgloss.Color(G.colors[column.color])).Render(char)
+func (G *Graph) lineWriteColumn(line *GraphLine, column *Column, kind GlyphKind) {
+	line.addGlyph(kind, column.color, column.commit)
 }
-func (G *Graph) outputPaddingLine(line *string) *string {
+func (G *Graph) outputPaddingLine(line *GraphLine) *GraphLine {
 	for i := 0; i < G.numNewColumns; i++ {
-		G.lineWriteColumn(line, G.newColumns[i], GRAPH_PRINT_PADDING)
-		*line += " "
+		G.lineWriteColumn(line, G.newColumns[i], GLYPH_PADDING)
+		line.addSpaces(1)
 	}
 	return line
 }
-func (G *Graph) outputSkipLine(line *string) *string {
-	*line = "..."
+func (G *Graph) outputSkipLine(line *GraphLine) *GraphLine {
+	line.addGlyph(GLYPH_SKIP, -1, plumbing.ZeroHash)
 	if G.needsPreCommitLine() {
 		G.updateState(GRAPH_PRE_COMMIT)
 	} else {
@@ -331,7 +637,7 @@ func (G *Graph) outputSkipLine(line *string) *string {
 	}
 	return line
 }
-func (G *Graph) outputPreCommitLine(line *string) *string {
+func (G *Graph) outputPreCommitLine(line *GraphLine) *GraphLine {
 	/// gotta flip em around from c
 	if G.numParents < 3 {
 		panic("dude, really? [G.numParents < 3]")
@@ -342,22 +648,22 @@ func (G *Graph) outputPreCommitLine(line *string) *string {
 	seenThis := false
 	for i := 0; i < G.numColumns; i++ {
 		column := G.columns[i]
-		if column.commit.Hash.String() == G.commit.Hash.String() {
+		if column.commit == G.node.ID() {
 			seenThis = true
-			G.lineWriteColumn(line, column, GRAPH_PRINT_PADDING)
-			*line += strings.Repeat(" ", G.expansionRow)
+			G.lineWriteColumn(line, column, GLYPH_PADDING)
+			line.addSpaces(G.expansionRow)
 		} else if seenThis && G.expansionRow == 0 {
 			if G.prevState == GRAPH_POST_MERGE && G.prevCommitIndex < i {
-				G.lineWriteColumn(line, column, GRAPH_PRINT_RMOVE)
+				G.lineWriteColumn(line, column, GLYPH_RMOVE)
 			} else {
-				G.lineWriteColumn(line, column, GRAPH_PRINT_PADDING)
+				G.lineWriteColumn(line, column, GLYPH_PADDING)
 			}
 		} else if seenThis && G.expansionRow > 0 {
-			G.lineWriteColumn(line, column, GRAPH_PRINT_RMOVE)
+			G.lineWriteColumn(line, column, GLYPH_RMOVE)
 		} else {
-			G.lineWriteColumn(line, column, GRAPH_PRINT_PADDING)
+			G.lineWriteColumn(line, column, GLYPH_PADDING)
 		}
-		*line += " "
+		line.addSpaces(1)
 	}
 	G.expansionRow++
 	if !G.needsPreCommitLine() {
@@ -365,45 +671,40 @@ func (G *Graph) outputPreCommitLine(line *string) *string {
 	}
 	return line
 }
-func (G *Graph) outputCommitLine(line *string) *string {
+func (G *Graph) outputCommitLine(line *GraphLine) *GraphLine {
 	seenThis := false
 	for i := 0; i <= G.numColumns; i++ {
 		column := G.columns[i]
-		var commit *object.Commit
+		var commit plumbing.Hash
 		if i == G.numColumns {
 			if seenThis {
 				break
 			}
-			commit = G.commit
+			commit = G.node.ID()
 		} else {
 			commit = column.commit
 		}
 
-		if commit.Hash.String() == G.commit.Hash.String() {
+		if commit == G.node.ID() {
 			seenThis = true
-			/// deviation: marking the root commit
-			if G.numParents == 0 {
-				*line += "R"
-			} else {
-				*line += GRAPH_PRINT_COMMIT
-			}
+			line.addGlyph(G.commitKind(), G.commitColor(), G.node.ID())
 			if G.numParents > 2 {
 				G.drawOctopusMerge(line)
 			}
 		} else if seenThis && G.edgesAdded > 1 {
-			G.lineWriteColumn(line, column, GRAPH_PRINT_PADDING)
+			G.lineWriteColumn(line, column, GLYPH_RMOVE)
 		} else if seenThis && G.edgesAdded == 1 {
 			if G.prevState == GRAPH_POST_MERGE && G.prevEdgesAdded > 0 && G.prevCommitIndex < i {
-				G.lineWriteColumn(line, column, GRAPH_PRINT_RMOVE)
+				G.lineWriteColumn(line, column, GLYPH_RMOVE)
 			} else {
-				G.lineWriteColumn(line, column, GRAPH_PRINT_PADDING)
+				G.lineWriteColumn(line, column, GLYPH_PADDING)
 			}
 		} else if G.prevState == GRAPH_COLLAPSING && G.oldMapping[2*i+1] == i && G.mapping[2*i] < i {
-			G.lineWriteColumn(line, column, GRAPH_PRINT_LMOVE)
+			G.lineWriteColumn(line, column, GLYPH_LMOVE)
 		} else {
-			G.lineWriteColumn(line, column, GRAPH_PRINT_PADDING)
+			G.lineWriteColumn(line, column, GLYPH_PADDING)
 		}
-		*line += " "
+		line.addSpaces(1)
 	}
 	if G.numParents > 1 {
 		G.updateState(GRAPH_POST_MERGE)
@@ -414,32 +715,54 @@ func (G *Graph) outputCommitLine(line *string) *string {
 	}
 	return line
 }
-func (G *Graph) outputPostMergeLine(line *string) *string {
+
+// deviation: git draws every commit as *
+// the first kind that fits wins
+func (G *Graph) commitKind() GlyphKind {
+	id := G.node.ID()
+	parents := len(G.node.ParentHashes())
+	if rewritten, ok := G.node.(Rewritten); ok {
+		parents = rewritten.OriginalParents()
+	}
+	switch {
+	case G.boundary != nil && G.boundary(id):
+		return GLYPH_BOUNDARY
+	case id == G.head:
+		return GLYPH_HEAD
+	case G.unreachable != nil && G.unreachable(id):
+		return GLYPH_UNREACHABLE
+	case parents == 0:
+		return GLYPH_ROOT
+	case parents > 1:
+		return GLYPH_MERGE
+	}
+	return GLYPH_COMMIT
+}
+func (G *Graph) outputPostMergeLine(line *GraphLine) *GraphLine {
 	seenThis := false
-	firstParent, _ := G.commit.Parent(0)
+	firstParent := G.parents[0]
 	var parentColumn *Column
 
 	/// FIXME: .edgesAdded is 1 here, when it should be 0? maybe?
 	for i := 0; i <= G.numColumns; i++ {
 		column := G.columns[i]
-		var colCommit *object.Commit
+		var colCommit plumbing.Hash
 		if i == G.numColumns {
 			if seenThis {
 				break
 			}
-			colCommit = G.commit
+			colCommit = G.node.ID()
 		} else {
 			colCommit = column.commit
 		}
-		if colCommit.Hash.String() == G.commit.Hash.String() {
-			parents := firstParent
+		if colCommit == G.node.ID() {
 			parentColumnIdx := -1
 			idx := G.mergeLayout
-			mergeChar := ""
+			var mergeChar GlyphKind
 			seenThis = true
 
-			for ii := 0; ii < G.numParents; ii++ {
-				parentColumnIdx = G.findNewColumnByCommit(parents)
+			for ii, parent := range G.parents {
+				parentColumnIdx = G.findNewColumnByCommit(parent)
 				if parentColumnIdx < 0 {
 					panic("[parentColumn < 0]")
 				}
@@ -447,34 +770,33 @@ func (G *Graph) outputPostMergeLine(line *string) *string {
 				G.lineWriteColumn(line, G.newColumns[parentColumnIdx], mergeChar)
 				if idx == 2 {
 					if G.edgesAdded > 0 || ii < G.numParents-1 {
-						*line += " "
+						line.addSpaces(1)
 					}
 				} else {
 					idx++
 				}
-				parents, _ = colCommit.Parent(ii + 1) //Parents().Next()
 			}
 			if G.edgesAdded == 0 {
-				*line += " "
+				line.addSpaces(1)
 			}
 		} else if seenThis {
 			if G.edgesAdded > 0 {
-				G.lineWriteColumn(line, column, GRAPH_PRINT_RMOVE)
+				G.lineWriteColumn(line, column, GLYPH_RMOVE)
 			} else {
-				G.lineWriteColumn(line, column, GRAPH_PRINT_PADDING)
+				G.lineWriteColumn(line, column, GLYPH_PADDING)
 			}
-			*line += " "
+			line.addSpaces(1)
 		} else {
-			G.lineWriteColumn(line, column, GRAPH_PRINT_PADDING)
+			G.lineWriteColumn(line, column, GLYPH_PADDING)
 			if G.mergeLayout != 0 || i != G.commitIndex-1 {
 				if parentColumn != nil {
-					G.lineWriteColumn(line, parentColumn, GRAPH_PRINT_BRIDGE)
+					G.lineWriteColumn(line, parentColumn, GLYPH_BRIDGE)
 				} else {
-					*line += " "
+					line.addSpaces(1)
 				}
 			}
 		}
-		if colCommit.Hash.String() == firstParent.Hash.String() {
+		if colCommit == firstParent {
 			parentColumn = column
 		}
 	}
@@ -485,7 +807,7 @@ func (G *Graph) outputPostMergeLine(line *string) *string {
 	}
 	return line
 }
-func (G *Graph) outputCollapsingLine(line *string) *string {
+func (G *Graph) outputCollapsingLine(line *GraphLine) *GraphLine {
 	usedHorizontal := false
 	horizontalEdge := -1
 	horizontalEdgeTarget := -1
@@ -546,20 +868,20 @@ func (G *Graph) outputCollapsingLine(line *string) *string {
 	for i := 0; i < G.mappingSize; i++ {
 		target := G.mapping[i]
 		if target < 0 {
-			*line += " "
+			line.addSpaces(1)
 		} else if target*2 == i {
-			G.lineWriteColumn(line, G.newColumns[target], GRAPH_PRINT_PADDING)
+			G.lineWriteColumn(line, G.newColumns[target], GLYPH_PADDING)
 		} else if target == horizontalEdgeTarget && i != horizontalEdge-1 {
 			if i != (target*2)+3 {
 				G.mapping[i] = -1
 			}
 			usedHorizontal = true
-			G.lineWriteColumn(line, G.newColumns[target], GRAPH_PRINT_BRIDGE)
+			G.lineWriteColumn(line, G.newColumns[target], GLYPH_BRIDGE)
 		} else {
 			if usedHorizontal && i < horizontalEdge {
 				G.mapping[i] = -1
 			}
-			G.lineWriteColumn(line, G.newColumns[target], GRAPH_PRINT_LMOVE)
+			G.lineWriteColumn(line, G.newColumns[target], GLYPH_LMOVE)
 		}
 	}
 	if G.isMappingCorrect() {
@@ -570,11 +892,11 @@ func (G *Graph) outputCollapsingLine(line *string) *string {
 
 type Column struct {
 	/// parent of the column
-	commit *object.Commit
+	commit plumbing.Hash
 	/// color index
 	color int
 }
 type CommitList struct {
-	commit     *object.Commit
+	commit     plumbing.Hash
 	commitList *CommitList
 }
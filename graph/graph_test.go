@@ -0,0 +1,267 @@
+package graph
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// a commit of a test graph, the hashes are made up from the names
+type testNode struct {
+	id      plumbing.Hash
+	parents []plumbing.Hash
+}
+
+func (N testNode) ID() plumbing.Hash {
+	return N.id
+}
+func (N testNode) ParentHashes() []plumbing.Hash {
+	return N.parents
+}
+
+func hashOf(name string) plumbing.Hash {
+	return plumbing.ComputeHash(plumbing.BlobObject, []byte(name))
+}
+
+// the commits are "name parent..." in the order theyre shown
+// the lines come out like `git log --graph --format=%s` prints them, without the spaces at the end
+func drawGraph(t *testing.T, G *Graph, theme *Theme, commits []string) []string {
+	t.Helper()
+	var blocks []Block
+	for _, commit := range commits {
+		name, parents, _ := strings.Cut(commit, " ")
+		node := testNode{id: hashOf(name)}
+		for _, parent := range strings.Fields(parents) {
+			node.parents = append(node.parents, hashOf(parent))
+		}
+		blocks = append(blocks, G.Block(node))
+	}
+	if G.reverse {
+		slices.Reverse(blocks)
+	}
+	names := make(map[plumbing.Hash]string, len(commits))
+	for _, commit := range commits {
+		name, _, _ := strings.Cut(commit, " ")
+		names[hashOf(name)] = name
+	}
+	text := &TextRenderer{Theme: theme}
+	var lines []string
+	for _, block := range blocks {
+		for i, row := range block.Rows {
+			line := text.Line(row)
+			if i == block.Commit {
+				line += names[row.Node.ID()]
+			}
+			lines = append(lines, strings.TrimRight(line, " "))
+		}
+	}
+	return lines
+}
+
+type graphTest struct {
+	name    string
+	commits []string
+	want    []string
+}
+
+func runGraphTests(t *testing.T, setup func(G *Graph), theme *Theme, tests []graphTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			G := New()
+			G.SetColors("red,green,yellow,blue,magenta,cyan")
+			if setup != nil {
+				setup(G)
+			}
+			got := drawGraph(t, G, theme, test.commits)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+// two merges in a row, git leaves a lone \ right of the second one
+var mergeChain = []string{"m2 m1 e", "m1 b c", "e a", "c a", "b a", "a"}
+
+// the wants are what `git log --graph --format=%s` prints for the same commits, but with rivera's R for roots
+func TestTextRenderer(t *testing.T) {
+	runGraphTests(t, nil, nil, []graphTest{
+		{"merge chain", mergeChain, []string{
+			"*   m2",
+			"|\\",
+			"* \\   m1",
+			"|\\ \\",
+			"| | * e",
+			"| * | c",
+			"| |/",
+			"* / b",
+			"|/",
+			"R a",
+		}},
+		{"octopus", []string{"o a b c d", "d a", "c a", "b a", "a"}, []string{
+			"*---.   o",
+			"|\\ \\ \\",
+			"| | | * d",
+			"| |_|/",
+			"|/| |",
+			"| | * c",
+			"| |/",
+			"|/|",
+			"| * b",
+			"|/",
+			"R a",
+		}},
+		/// o opens two columns, so y and z move over by two on its commit row
+		{"octopus between lanes", []string{"m x o y z", "x a", "o b c d", "d a", "c a", "b a", "z a", "y a", "a"}, []string{
+			"*---.   m",
+			"|\\ \\ \\",
+			"* | | | x",
+			"| | | |",
+			"| |  \\ \\",
+			"| *-. \\ \\   o",
+			"| |\\ \\ \\ \\",
+			"| | | * | | d",
+			"| |_|/ / /",
+			"|/| | | |",
+			"| | * | | c",
+			"| |/ / /",
+			"|/| | |",
+			"| * | | b",
+			"|/ / /",
+			"| | * z",
+			"| |/",
+			"|/|",
+			"| * y",
+			"|/",
+			"R a",
+		}},
+	})
+}
+
+// the lanes of the ascii graph with the corners rounded off
+func TestRoundedTheme(t *testing.T) {
+	runGraphTests(t, nil, Themes["rounded"], []graphTest{
+		/// no bend for the \ right of m1, it would run into the commit
+		{"merge chain", mergeChain, []string{
+			"*   m2",
+			"├─╮",
+			"* ╲   m1",
+			"│╲ ╲",
+			"│ │ * e",
+			"│ * │ c",
+			"│ ├─╯",
+			"* ╱ b",
+			"├─╯",
+			"R a",
+		}},
+		/// the octopus dashes join the lanes they drop into
+		{"octopus", []string{"o x y z w", "w", "z", "y", "x"}, []string{
+			"*─┬─╮   o",
+			"│╲ ╲ ╲",
+			"│ │ │ R w",
+			"│ │ R z",
+			"│ R y",
+			"R x",
+		}},
+	})
+}
+
+// the wants are the TestTextRenderer ones upside down, with \ and / swapped, _ as ‾ and the octopus . as '
+func TestReverse(t *testing.T) {
+	reverse := func(G *Graph) { G.SetReverse(true) }
+	runGraphTests(t, reverse, nil, []graphTest{
+		{"merge chain", mergeChain, []string{
+			"R a",
+			"|\\",
+			"* \\ b",
+			"| |\\",
+			"| * | c",
+			"| | * e",
+			"|/ /",
+			"* /   m1",
+			"|/",
+			"*   m2",
+		}},
+		{"octopus", []string{"o a b c d", "d a", "c a", "b a", "a"}, []string{
+			"R a",
+			"|\\",
+			"| * b",
+			"|\\|",
+			"| |\\",
+			"| | * c",
+			"|\\| |",
+			"| |‾|\\",
+			"| | | * d",
+			"|/ / /",
+			"*---'   o",
+		}},
+	})
+	/// the merges open up into the commits above them now
+	runGraphTests(t, reverse, Themes["rounded"], []graphTest{
+		{"rounded merge chain", mergeChain, []string{
+			"R a",
+			"├─╮",
+			"* ╲ b",
+			"│ ├─╮",
+			"│ * │ c",
+			"│ │ * e",
+			"│╱ ╱",
+			"* ╱   m1",
+			"├─╯",
+			"*   m2",
+		}},
+	})
+}
+
+func TestSetGlyphs(t *testing.T) {
+	tests := []struct {
+		spec string
+		kind GlyphKind
+		want string
+	}{
+		{"", GLYPH_MERGE, GRAPH_PRINT_COMMIT},
+		{"ascii", GLYPH_MERGE, GRAPH_PRINT_COMMIT},
+		{"ascii", GLYPH_ROOT, GRAPH_PRINT_ROOT},
+		{"marks", GLYPH_MERGE, GRAPH_PRINT_MERGE},
+		{"unicode", GLYPH_HEAD, "◉"},
+		/// later items win
+		{"unicode,head=@", GLYPH_HEAD, "@"},
+		{"head=@,unicode", GLYPH_HEAD, "◉"},
+		{" marks , root=x ", GLYPH_ROOT, "x"},
+		{"merge=◆", GLYPH_MERGE, "◆"},
+		{"merge=◆", GLYPH_COMMIT, GRAPH_PRINT_COMMIT},
+	}
+	for _, test := range tests {
+		G := New()
+		if err := G.SetGlyphs(test.spec); err != nil {
+			t.Errorf("SetGlyphs(%q): %v", test.spec, err)
+			continue
+		}
+		if got := G.glyphFor(test.kind); got != test.want {
+			t.Errorf("SetGlyphs(%q) draws %s as %q, want %q", test.spec, test.kind, got, test.want)
+		}
+	}
+	for _, test := range []struct{ spec, want string }{
+		{"sparkly", "unknown glyph preset 'sparkly'"},
+		{"unicode,Marks", "unknown glyph preset 'Marks'"},
+		{"tree=T", "unknown glyph kind 'tree'"},
+		{"padding=.", "unknown glyph kind 'padding'"},
+		{"head=@@", "glyph for head should be one character, got '@@'"},
+		{"merge=◆◆", "glyph for merge should be one character, got '◆◆'"},
+		{"root=", "glyph for root should be one character, got ''"},
+	} {
+		G := New()
+		G.SetGlyphs("marks")
+		err := G.SetGlyphs(test.spec)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("SetGlyphs(%q) got error %v, want one saying %q", test.spec, err, test.want)
+		}
+		/// a bad spec changes nothing
+		if got := G.glyphFor(GLYPH_MERGE); got != GRAPH_PRINT_MERGE {
+			t.Errorf("after SetGlyphs(%q) merges are drawn as %q, want %q", test.spec, got, GRAPH_PRINT_MERGE)
+		}
+	}
+}
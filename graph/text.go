@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// draws rows as lines of text, colored with lipgloss
+type TextRenderer struct {
+	/// nil just writes them into Lines
+	Out io.Writer
+	/// passed straight to lipgloss.Color, indexed by Cell.Color
+	Colors []string
+	/// nil draws the cells as they are
+	Theme *Theme
+	Lines []string
+	/// lipgloss is slow, and wide rivers draw the same few glyphs thousands of times a row
+	styled map[styledGlyph]string
+}
+type styledGlyph struct {
+	glyph string
+	color int
+}
+
+func (T *TextRenderer) RenderRow(row Row) error {
+	line := T.Line(row)
+	if T.Out == nil {
+		T.Lines = append(T.Lines, line)
+		return nil
+	}
+	_, err := fmt.Fprintln(T.Out, line)
+	return err
+}
+
+// colors the row and fills in the spaces
+func (T *TextRenderer) Line(row Row) string {
+	line := strings.Builder{}
+	width := 0
+	for _, cell := range T.Theme.draw(row.Cells) {
+		line.WriteString(strings.Repeat(" ", cell.Column-width))
+		/// git leaves the commit itself uncolored, the other kinds get their lanes color so they stand out
+		if cell.Color < 0 || cell.Color >= len(T.Colors) || cell.Kind == GLYPH_COMMIT {
+			line.WriteString(cell.Glyph)
+		} else {
+			line.WriteString(T.style(cell.Glyph, cell.Color))
+		}
+		width = cell.Column + utf8.RuneCountInString(cell.Glyph)
+	}
+	/// bends can reach past the last cell
+	line.WriteString(strings.Repeat(" ", max(row.Width-width, 0)))
+	return line.String()
+}
+
+func (T *TextRenderer) style(glyph string, color int) string {
+	key := styledGlyph{glyph, color}
+	if styled, ok := T.styled[key]; ok {
+		return styled
+	}
+	if T.styled == nil {
+		T.styled = make(map[styledGlyph]string)
+	}
+	styled := lipgloss.NewStyle().Foreground(lipgloss.Color(T.Colors[color])).Render(glyph)
+	T.styled[key] = styled
+	return styled
+}
@@ -0,0 +1,217 @@
+package graph
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+// which sides of a cell the lanes leave through
+type connection uint8
+
+const (
+	UP connection = 1 << iota
+	DOWN
+	LEFT
+	RIGHT
+	/// \ and /, theyre corner to corner so they dont mix with the others
+	DIAGONAL_RIGHT
+	DIAGONAL_LEFT
+)
+
+// the names themes are written with, see LoadTheme
+var connectionNames = map[string]connection{
+	"vertical":        UP | DOWN,
+	"horizontal":      LEFT | RIGHT,
+	"cross":           UP | DOWN | LEFT | RIGHT,
+	"rmove":           DIAGONAL_RIGHT,
+	"lmove":           DIAGONAL_LEFT,
+	"down-left":       DOWN | LEFT,
+	"down-right":      DOWN | RIGHT,
+	"up-left":         UP | LEFT,
+	"up-right":        UP | RIGHT,
+	"vertical-left":   UP | DOWN | LEFT,
+	"vertical-right":  UP | DOWN | RIGHT,
+	"horizontal-down": LEFT | RIGHT | DOWN,
+	"horizontal-up":   LEFT | RIGHT | UP,
+}
+
+// how the lanes get drawn, the commits keep their own glyphs
+// nil glyphs draws the cells as they are, thats the ascii git draws
+type Theme struct {
+	glyphs map[connection]string
+	/// lone \ and / turn into corners when the column next to them is free, like ├─╮
+	bends bool
+}
+
+var unicodeGlyphs = map[connection]string{
+	UP | DOWN:                "│",
+	LEFT | RIGHT:             "─",
+	UP | DOWN | LEFT | RIGHT: "┼",
+	DIAGONAL_RIGHT:           "╲",
+	DIAGONAL_LEFT:            "╱",
+	DOWN | LEFT:              "┐",
+	DOWN | RIGHT:             "┌",
+	UP | LEFT:                "┘",
+	UP | RIGHT:               "└",
+	UP | DOWN | LEFT:         "┤",
+	UP | DOWN | RIGHT:        "├",
+	LEFT | RIGHT | DOWN:      "┬",
+	LEFT | RIGHT | UP:        "┴",
+}
+
+var Themes = map[string]*Theme{
+	"ascii":   {},
+	"unicode": {glyphs: unicodeGlyphs},
+	"rounded": {glyphs: withGlyphs(unicodeGlyphs, map[connection]string{
+		DOWN | LEFT:  "╮",
+		DOWN | RIGHT: "╭",
+		UP | LEFT:    "╯",
+		UP | RIGHT:   "╰",
+	}), bends: true},
+}
+
+func withGlyphs(base, changes map[connection]string) map[connection]string {
+	glyphs := maps.Clone(base)
+	maps.Copy(glyphs, changes)
+	return glyphs
+}
+
+// a theme from settings like the ones in a [rivera-theme "name"] section,
+// base picks the theme to start from (unicode when missing), bends is a bool, the rest are glyphs named in connectionNames
+func LoadTheme(settings map[string]string) (*Theme, error) {
+	baseName, ok := settings["base"]
+	if !ok {
+		baseName = "unicode"
+	}
+	base, ok := Themes[baseName]
+	if !ok {
+		return nil, fmt.Errorf("unknown base theme '%s', want ascii, unicode or rounded", baseName)
+	}
+	theme := &Theme{glyphs: maps.Clone(base.glyphs), bends: base.bends}
+	if theme.glyphs == nil {
+		theme.glyphs = make(map[connection]string)
+	}
+	for key, value := range settings {
+		switch key {
+		case "base":
+			continue
+		case "bends":
+			bends, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("bad bends '%s', want true or false", value)
+			}
+			theme.bends = bends
+			continue
+		}
+		connections, ok := connectionNames[key]
+		if !ok {
+			names := slices.Sorted(maps.Keys(connectionNames))
+			return nil, fmt.Errorf("unknown theme glyph '%s', want base, bends or one of %v", key, names)
+		}
+		/// the columns are counted in characters
+		if utf8.RuneCountInString(value) != 1 {
+			return nil, fmt.Errorf("glyph for %s should be one character, got '%s'", key, value)
+		}
+		theme.glyphs[connections] = value
+	}
+	return theme, nil
+}
+
+// the cells of a row with the glyphs the theme picks
+// every cell gets looked at with its neighbors, a lane between two horizontal ones is a crossing,
+// and bends can add cells where the row was blank
+func (T *Theme) draw(cells []Cell) []Cell {
+	if T == nil || T.glyphs == nil {
+		return cells
+	}
+	byColumn := make(map[int]*Cell, len(cells))
+	joins := make(map[int]connection, len(cells))
+	drawn := make([]Cell, len(cells))
+	for i, cell := range cells {
+		drawn[i] = cell
+		byColumn[cell.Column] = &drawn[i]
+		joins[cell.Column] = cell.Kind.connections(cell.Column)
+	}
+	isMove := func(column int) bool {
+		cell, ok := byColumn[column]
+		return ok && (cell.Kind == GLYPH_RMOVE || cell.Kind == GLYPH_LMOVE)
+	}
+
+	var added []Cell
+	if T.bends {
+		for _, cell := range cells {
+			c := cell.Column
+			if !isMove(c) || byColumn[c+1] != nil || isMove(c-2) || isMove(c+2) {
+				continue
+			}
+			/// the \ git leaves right of a commit after a merge, a corner would run into the commit
+			if commit, ok := byColumn[c-2]; ok && commit.Kind.IsCommit() {
+				continue
+			}
+			/// \ comes down from the left and leaves to the right, / the other way around
+			left, right := UP|RIGHT, DOWN|LEFT
+			if cell.Kind == GLYPH_LMOVE {
+				left, right = DOWN|RIGHT, UP|LEFT
+			}
+			joins[c] = LEFT | RIGHT
+			if byColumn[c-1] == nil {
+				added = append(added, Cell{Kind: cell.Kind, Column: c - 1, Color: cell.Color, Commit: cell.Commit})
+			}
+			joins[c-1] |= left
+			added = append(added, Cell{Kind: cell.Kind, Column: c + 1, Color: cell.Color, Commit: cell.Commit})
+			joins[c+1] |= right
+		}
+	}
+	for _, cell := range cells {
+		c := cell.Column
+		/// a bridge running through a lane
+		if cell.Kind == GLYPH_PADDING && joins[c-1]&RIGHT != 0 && (joins[c+1]&LEFT != 0 || isMove(c+1)) {
+			joins[c] |= LEFT | RIGHT
+		}
+	}
+
+	drawn = append(drawn, added...)
+	slices.SortFunc(drawn, func(a, b Cell) int { return a.Column - b.Column })
+	for i, cell := range drawn {
+		if cell.Kind.IsCommit() || cell.Kind == GLYPH_SKIP {
+			continue
+		}
+		if glyph, ok := T.glyphs[joins[cell.Column]]; ok {
+			drawn[i].Glyph = glyph
+		}
+	}
+	return drawn
+}
+
+// how the kind connects on its own, before the neighbors get a say
+func (K GlyphKind) connections(column int) connection {
+	switch K {
+	case GLYPH_PADDING:
+		return UP | DOWN
+	case GLYPH_RMOVE:
+		return DIAGONAL_RIGHT
+	case GLYPH_LMOVE:
+		return DIAGONAL_LEFT
+	case GLYPH_BRIDGE, GLYPH_BRIDGE_OVER:
+		return LEFT | RIGHT
+	/// the octopus parents drop down from the even columns
+	case GLYPH_MULTIBRANCH_EXTENSION:
+		if column%2 == 0 {
+			return LEFT | RIGHT | DOWN
+		}
+		return LEFT | RIGHT
+	case GLYPH_MULTIBRANCH_EXTENSION_UP:
+		if column%2 == 0 {
+			return LEFT | RIGHT | UP
+		}
+		return LEFT | RIGHT
+	case GLYPH_MULTIBRANCH_START:
+		return LEFT | DOWN
+	case GLYPH_MULTIBRANCH_START_UP:
+		return LEFT | UP
+	}
+	return 0
+}
@@ -1,204 +1,106 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/signal"
-	"strings"
-	"syscall"
+	"os/exec"
+	"path/filepath"
 
-	"rivera/graph"
+	"rivera/river"
 
-	"github.com/charmbracelet/lipgloss"
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
 	"github.com/urfave/cli/v2"
 )
 
-var config = struct {
-	repoPath, branchcolors string
-	hashLen                int
-	reverse, displayAll    bool
-}{}
-
 func main() {
 	app := &cli.App{
 		Name:                   "rivera",
 		Version:                "0.0.1",
 		Usage:                  "display the git river, like git-forest",
+		Description:            "any option left off the command line can come from the [rivera] section of git config,\nlike `git config --global rivera.hashlength 12`, the repository config wins over the global one",
 		UseShortOptionHandling: true,
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:    "repository",
-				Usage:   "repository `path` to use",
-				Aliases: []string{"repo", "r"},
-				Value:   ".",
-			},
-			&cli.IntFlag{
-				Name:    "hashlength",
-				Usage:   "`len`gth of the commit hash",
-				Aliases: []string{"l"},
-				Value:   8,
-			},
-			// &cli.BoolFlag{
-			// 	Name:  "all",
-			// 	Usage: "display all branches",
-			// 	Value: false,
-			// },
-			&cli.BoolFlag{
-				Name:  "reverse",
-				Usage: "reverse the display",
-				Value: false,
-			},
-			&cli.StringFlag{
-				Name:  "branchcolors",
-				Usage: "comma separated `color,color[,color]` used for branches, passed straight to lipgloss.Color",
-				Value: "#7272A8, #ff00ff, #b00b69, #e5ebb7, #11bf7b",
-			},
-		},
+		/// patterns can have commas in them
+		DisableSliceFlagSeparator: true,
+		ArgsUsage:                 "[<revision range>...] [-- <path>...]",
+		Flags:                     river.Flags,
 		Action: func(ctx *cli.Context) error {
-			config.repoPath = ctx.String("repository")
-			config.displayAll = ctx.Bool("all")
-			config.reverse = ctx.Bool("reverse")
-			config.hashLen = ctx.Int("hashlength")
-			config.branchcolors = ctx.String("branchcolors")
-
-			repo, err := git.PlainOpen(config.repoPath)
-			if err != nil {
+			if err := river.ReadConfig(ctx); err != nil {
 				return err
 			}
-			nodeIndex := commitgraph.NewObjectCommitNodeIndex(repo.Storer)
-
-			head, err := repo.Head()
+			r, err := river.Open(ctx)
 			if err != nil {
 				return err
 			}
-			headCommit, _ := nodeIndex.Get(head.Hash())
-
-			iter := commitgraph.NewCommitNodeIterTopoOrder(headCommit, nil, nil)
-			defer iter.Close()
-			// iter, err := repo.Log(&git.LogOptions{
-			// 	From:  head.Hash(),
-			// 	Order: git.LogOrderCommitterTime, /// not certain this works :\
-			// 	All:   config.displayAll,
-			// })
-			// if err != nil {
-			// 	return err
-			// }
-			refs, _ := repo.References()
-			defer refs.Close()
-
-			tagMap := make(map[string][]string)
-			branchMap := make(map[string][]string)
-			refs.ForEach(func(ref *plumbing.Reference) error {
-				switch ref.Type() {
-				case plumbing.HashReference:
-					{
-						hash := ref.Hash().String()
-						name := ref.Name()
-						if (name.IsTag()) {
-							if _, ok := tagMap[hash]; !ok {
-								tagMap[hash] = make([]string, 0, 4)
-							}
-							tagMap[hash] = append(tagMap[hash], colorize("tag: ", "5")+colorize(name.Short(), "3"))
-						}
-						if name.IsRemote() || name.IsBranch() {
-							if _, ok := branchMap[hash]; !ok {
-								branchMap[hash] = make([]string, 0, 4)
-							}
-							branchMap[hash] = append(branchMap[hash], colorize(name.Short(), "1"))
-						}
-					}
-				}
-				return nil
-			})
-
-			/// now, we build the river
-			g := graph.New()
-			g.SetColors(config.branchcolors)
-			lines := make([]string, 0, 64)
-			iter.ForEach(func(cn commitgraph.CommitNode) error {
-				c, _ := cn.Commit()
-				g.Update(c)
-				for {
-					if g.IsCommitFinished() {
-						break
-					}
-					line, isCommit := g.NextLine()
-					if config.reverse {
-						/// TODO: do we have to do this? i think so lol
-						line = strings.ReplaceAll(line, graph.GRAPH_PRINT_RMOVE, "t")
-						line = strings.ReplaceAll(line, graph.GRAPH_PRINT_LMOVE, graph.GRAPH_PRINT_RMOVE)
-						line = strings.ReplaceAll(line, "t", graph.GRAPH_PRINT_LMOVE)
-						line = strings.ReplaceAll(line, graph.GRAPH_PRINT_BRIDGE, "‾")
-					}
-
-					if isCommit {
-						lines = append(lines, printCommit(c, line, tagMap, branchMap, head.Hash().String() == c.Hash.String()))
-					} else {
-						/// TODO: can we not hardcode this?
-						lines = append(lines, fmt.Sprintf("%s%s", strings.Repeat(" ", 18+config.hashLen), line))
-					}
-				}
-				return nil
-			})
-			if config.reverse {
-				for i := len(lines) - 1; i > -1; i-- {
-					line := lines[i]
-					fmt.Println(line)
-				}
-			} else {
-				for _, line := range lines {
-					fmt.Println(line)
-				}
-			}
-			return nil
+			defer r.Close()
+			return r.Print()
 		},
 	}
-	/// discard sigpipe
-	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, syscall.SIGPIPE)
-		<-c
-	}()
-	if err := app.Run(os.Args); err != nil {
+	app.Commands = []*cli.Command{
+		{
+			Name:      "tui",
+			Usage:     "browse the river interactively, runs rivera-tui",
+			ArgsUsage: "[<revision range>...] [-- <path>...]",
+			/// rivera-tui reads its own options
+			SkipFlagParsing: true,
+			Action:          runTUI,
+		},
+	}
+	if err := app.Run(river.ReorderArgs(os.Args)); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func colorize(text, color string) string {
-	return lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(text)
+// the tui is its own binary, bubbletea asks the terminal about its colors as soon as its loaded
+// looked for next to rivera first, then on $PATH, like git does with git-<command>
+func runTUI(ctx *cli.Context) error {
+	tui, err := exec.LookPath("rivera-tui")
+	if self, selfErr := os.Executable(); selfErr == nil {
+		if beside := filepath.Join(filepath.Dir(self), "rivera-tui"); isExecutable(beside) {
+			tui, err = beside, nil
+		}
+	}
+	if err != nil {
+		return errors.New("rivera tui needs rivera-tui, go install rivera/cmd/rivera-tui")
+	}
+	cmd := exec.Command(tui, append(parentFlags(ctx), ctx.Args().Slice()...)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		/// rivera-tui already said what went wrong
+		os.Exit(exit.ExitCode())
+	}
+	return err
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode()&0o111 != 0
 }
-func printCommit(c *object.Commit, graphLine string, tagMap, branchMap map[string][]string, isHead bool) string {
-	line := ""
-	hash := c.Hash.String()
-	timestamp := c.Committer.When.Format("2006-01-02 15:04") /// literally what is this
-	author := c.Committer.Name
-	summary := strings.Split(c.Message, "\n")[0]
-	tags, tagOk := tagMap[hash]
-	branches, branchOk := branchMap[hash]
 
-	line = fmt.Sprintf("%s %s %s %s",
-		colorize(hash[:config.hashLen], "5"),
-		colorize(timestamp, "4"),
-		graphLine,
-		colorize(author, "3"))
-	if isHead || tagOk || branchOk {
-		line += colorize(" (", "4")
-		if isHead {
-			line += colorize("HEAD %", "6")
-			if tagOk || branchOk {
-				line += " "
+// options can go before or after the subcommand, the ones before it go first so the ones after win
+func parentFlags(ctx *cli.Context) []string {
+	lineage := ctx.Lineage()
+	if len(lineage) < 2 {
+		return nil
+	}
+	parent := lineage[1]
+	var args []string
+	for _, flag := range river.Flags {
+		/// aliases only get synced up while parsing, so always pass the real name
+		name := flag.Names()[0]
+		if !parent.IsSet(name) {
+			continue
+		}
+		/// slices would come out as one "[a b]"
+		if _, ok := flag.(*cli.StringSliceFlag); ok {
+			for _, value := range parent.StringSlice(name) {
+				args = append(args, "--"+name+"="+value)
 			}
+			continue
 		}
-		refLine := append(append(make([]string, 0, 2), tags[:]...), branches[:]...)
-		line += fmt.Sprintf("%s", strings.Join(refLine, ", "))
-		line += colorize(")", "4")
+		args = append(args, fmt.Sprintf("--%s=%v", name, parent.Value(name)))
 	}
-
-	line += fmt.Sprintf(" %s", summary)
-	return line
+	return args
 }
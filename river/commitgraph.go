@@ -0,0 +1,129 @@
+package river
+
+import (
+	"path"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	commitgraphfmt "github.com/go-git/go-git/v5/plumbing/format/commitgraph/v2"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// reads nodes out of .git/objects/info/commit-graph (or the split chain) when git wrote one,
+// which skips decoding whole commits and gives the topo walk generation numbers to stop early with
+// commits newer than the file come from the objects, go-git falls back on its own for those
+// the returned index is nil when theres no usable file, close it when done otherwise
+func commitNodeIndex(repo *git.Repository) (commitgraph.CommitNodeIndex, commitgraphfmt.Index) {
+	index := openCommitGraph(repo)
+	if index == nil {
+		return shallowNodeIndex(repo, commitgraph.NewObjectCommitNodeIndex(repo.Storer)), nil
+	}
+	return shallowNodeIndex(repo, commitgraph.NewGraphCommitNodeIndex(index, repo.Storer)), index
+}
+
+// a shallow clone never fetched the parents of the commits in .git/shallow,
+// git treats those as roots and so does the walk, anything else missing is still an error
+func shallowNodeIndex(repo *git.Repository, index commitgraph.CommitNodeIndex) commitgraph.CommitNodeIndex {
+	hashes, err := repo.Storer.Shallow()
+	if err != nil || len(hashes) == 0 {
+		return index
+	}
+	shallow := make(map[plumbing.Hash]bool, len(hashes))
+	for _, hash := range hashes {
+		shallow[hash] = true
+	}
+	return &shallowIndex{CommitNodeIndex: index, shallow: shallow}
+}
+
+type shallowIndex struct {
+	commitgraph.CommitNodeIndex
+	shallow map[plumbing.Hash]bool
+}
+
+func (I *shallowIndex) Get(hash plumbing.Hash) (commitgraph.CommitNode, error) {
+	node, err := I.CommitNodeIndex.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &shallowNode{CommitNode: node, index: I}, nil
+}
+
+// the nodes parents come from the wrapped index, so they get wrapped on the way out too
+type shallowNode struct {
+	commitgraph.CommitNode
+	index *shallowIndex
+}
+
+func (N *shallowNode) NumParents() int {
+	if N.index.shallow[N.ID()] {
+		return 0
+	}
+	return N.CommitNode.NumParents()
+}
+
+func (N *shallowNode) ParentHashes() []plumbing.Hash {
+	if N.index.shallow[N.ID()] {
+		return nil
+	}
+	return N.CommitNode.ParentHashes()
+}
+
+func (N *shallowNode) ParentNode(i int) (commitgraph.CommitNode, error) {
+	if N.index.shallow[N.ID()] {
+		return nil, object.ErrParentNotFound
+	}
+	parent, err := N.CommitNode.ParentNode(i)
+	if err != nil {
+		return nil, err
+	}
+	return &shallowNode{CommitNode: parent, index: N.index}, nil
+}
+
+// nil when its missing, turned off, or broken
+func openCommitGraph(repo *git.Repository) commitgraphfmt.Index {
+	/// same switch git has
+	if value, ok := gitConfigValue(repo, "core", "commitGraph"); ok {
+		switch strings.ToLower(value) {
+		case "false", "no", "off", "0":
+			return nil
+		}
+	}
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return nil
+	}
+	fs := storage.Filesystem()
+	if _, err := fs.Stat(path.Join("objects", "info", "commit-graph")); err != nil && !chainComplete(fs) {
+		return nil
+	}
+	index, err := commitgraphfmt.OpenChainOrFileIndex(fs)
+	if err != nil {
+		return nil
+	}
+	return index
+}
+
+// go-git trips over a chain with a layer gone missing (gc mid-write, a half copied repo),
+// so check every file is there before handing it over
+func chainComplete(fs billy.Filesystem) bool {
+	dir := path.Join("objects", "info", "commit-graphs")
+	chainFile, err := fs.Open(path.Join(dir, "commit-graph-chain"))
+	if err != nil {
+		return false
+	}
+	defer chainFile.Close()
+	layers, err := commitgraphfmt.OpenChainFile(chainFile)
+	if err != nil || len(layers) == 0 {
+		return false
+	}
+	for _, layer := range layers {
+		if _, err := fs.Stat(path.Join(dir, "graph-"+layer+".graph")); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,310 @@
+package river
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/urfave/cli/v2"
+)
+
+var config = struct {
+	repoPath, branchcolors  string
+	glyphs, theme           string
+	format, name, timestamp string
+	dateMode                dateMode
+	output                  string
+	hashLen, maxCount, skip int
+	reverse, displayAll     bool
+	boundary, firstParent   bool
+	/// commit filters
+	authors, committers, greps []string
+	invertGrep, ignoreCase     bool
+	since, until               time.Time
+	noPager                    bool
+}{}
+
+// the options rivera and rivera-tui both take
+var Flags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "repository",
+		Usage:   "repository `path` to use",
+		Aliases: []string{"repo", "r"},
+		Value:   ".",
+	},
+	&cli.IntFlag{
+		Name:    "hashlength",
+		Usage:   "`len`gth of the commit hash",
+		Aliases: []string{"l"},
+		Value:   8,
+	},
+	&cli.BoolFlag{
+		Name:  "all",
+		Usage: "display all branches, remotes and tags",
+		Value: false,
+	},
+	&cli.IntFlag{
+		Name:    "max-count",
+		Usage:   "stop after `num` commits, negative means no limit",
+		Aliases: []string{"n"},
+		Value:   -1,
+	},
+	&cli.IntFlag{
+		Name:  "skip",
+		Usage: "skip `num` commits before starting to show them",
+		Value: 0,
+	},
+	&cli.StringSliceFlag{
+		Name:  "author",
+		Usage: "only commits whose author matches the `regex`, any of them when given more than once",
+	},
+	&cli.StringSliceFlag{
+		Name:  "committer",
+		Usage: "only commits whose committer matches the `regex`, any of them when given more than once",
+	},
+	&cli.StringSliceFlag{
+		Name:  "grep",
+		Usage: "only commits whose message matches the `regex`, any of them when given more than once",
+	},
+	&cli.BoolFlag{
+		Name:  "invert-grep",
+		Usage: "only commits whose message matches none of the --grep patterns",
+		Value: false,
+	},
+	&cli.BoolFlag{
+		Name:    "regexp-ignore-case",
+		Usage:   "match --author, --committer and --grep regardless of case",
+		Aliases: []string{"i"},
+		Value:   false,
+	},
+	&cli.StringFlag{
+		Name:    "since",
+		Usage:   "only commits newer than `date`, like 2024-01-02 or \"2 weeks ago\"",
+		Aliases: []string{"after"},
+	},
+	&cli.StringFlag{
+		Name:    "until",
+		Usage:   "only commits older than `date`",
+		Aliases: []string{"before"},
+	},
+	&cli.BoolFlag{
+		Name:  "first-parent",
+		Usage: "only follow the first parent of merges, which still get marked with an M unless the glyphs set one",
+		Value: false,
+	},
+	&cli.BoolFlag{
+		Name:  "boundary",
+		Usage: "also show where the walk stopped, the excluded parents, marked with an o",
+		Value: false,
+	},
+	&cli.BoolFlag{
+		Name:  "reverse",
+		Usage: "reverse the display",
+		Value: false,
+	},
+	&cli.StringFlag{
+		Name:  "format",
+		Usage: "commit line `format`, git --pretty=format placeholders plus %R for the river",
+		Value: defaultFormat,
+	},
+	&cli.StringFlag{
+		Name:  "name",
+		Usage: "show the `author`, the committer, or both when they differ",
+		Value: "author",
+	},
+	&cli.StringFlag{
+		Name:  "timestamp",
+		Usage: "show the `author` or the committer date",
+		Value: "author",
+	},
+	&cli.StringFlag{
+		Name:  "date",
+		Usage: "date `format`: relative, local, iso, iso-strict, rfc, short, unix, raw or format:<strftime>, add -local for your timezone",
+		Value: "default",
+	},
+	&cli.StringFlag{
+		Name:    "output",
+		Usage:   "output `kind`: text, json, ndjson, svg or html",
+		Aliases: []string{"o"},
+		Value:   "text",
+	},
+	&cli.BoolFlag{
+		Name:  "no-pager",
+		Usage: "print straight to stdout instead of going through the pager",
+		Value: false,
+	},
+	&cli.StringFlag{
+		Name:  "branchcolors",
+		Usage: "comma separated `color,color[,color]` used for branches, passed straight to lipgloss.Color",
+		Value: "#7272A8, #ff00ff, #b00b69, #e5ebb7, #11bf7b",
+	},
+	&cli.StringFlag{
+		Name:  "glyphs",
+		Usage: "commit `glyphs`, a preset (ascii draws * like git, marks and unicode tell the kinds apart) and kind=glyph overrides for commit, merge, root, head, boundary and unreachable (from HEAD with --all), like unicode,head=@",
+		Value: "ascii",
+	},
+	&cli.StringFlag{
+		Name:  "theme",
+		Usage: "how the lanes are drawn: ascii, unicode, rounded, or the `name` of a [rivera-theme \"name\"] section in git config",
+		Value: "ascii",
+	},
+}
+
+// cli stops reading options at the first revision, git doesnt, so `rivera main -n 5` gets its options moved up front
+// everything from a -- on stays where it is, with a second -- in front when nothing else is,
+// flag parsing eats the first and cli would take `rivera -- h` for the help command otherwise
+func ReorderArgs(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	takesValue := make(map[string]bool)
+	for _, flag := range Flags {
+		_, isBool := flag.(*cli.BoolFlag)
+		for _, name := range flag.Names() {
+			takesValue[name] = !isBool
+		}
+	}
+	options, rest := []string{args[0]}, []string{}
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			rest = append(rest, arg)
+			continue
+		}
+		options = append(options, arg)
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if _, known := takesValue[name]; !known && !strings.HasPrefix(arg, "--") {
+			/// -n50 or -in50, a value stuck onto the short option that takes it, the way git reads it
+			if at := strings.IndexFunc(name, func(r rune) bool { return takesValue[string(r)] }); at >= 0 && !shortFlags(name[at+1:], takesValue) {
+				options[len(options)-1] = "-" + name[:at+1]
+				options = append(options, name[at+1:])
+				continue
+			}
+			/// -ain 5, bundled short options, only the last one can take a value
+			name = name[len(name)-1:]
+		}
+		if takesValue[name] && i+1 < len(args) {
+			i++
+			options = append(options, args[i])
+		}
+	}
+	if len(rest) > 0 && rest[0] == "--" {
+		rest = append([]string{"--"}, rest...)
+	}
+	return append(options, rest...)
+}
+
+// whether every letter in bundle is a short option, as opposed to a value
+func shortFlags(bundle string, takesValue map[string]bool) bool {
+	for _, r := range bundle {
+		if _, known := takesValue[string(r)]; !known {
+			return false
+		}
+	}
+	return true
+}
+
+// fills in the flags that werent given from [rivera] in git config, keyed by the long flag name
+// the command line wins, then the repository config, then ~/.gitconfig, then the system one
+func gitConfigFlags(ctx *cli.Context) error {
+	/// a repo that doesnt open still has the global config, openRiver reports the error
+	repo, _ := git.PlainOpen(ctx.String("repository"))
+	for _, flag := range ctx.Command.Flags {
+		name := flag.Names()[0]
+		if name == "repository" || ctx.IsSet(name) {
+			continue
+		}
+		for _, value := range gitConfigValues(repo, "rivera", name) {
+			if _, ok := flag.(*cli.BoolFlag); ok {
+				value = gitBool(value)
+			}
+			if err := ctx.Set(name, value); err != nil {
+				return fmt.Errorf("bad rivera.%s '%s' in git config: %w", name, value, err)
+			}
+		}
+	}
+	return nil
+}
+
+// git spells booleans more ways than strconv does, a bare key means true
+func gitBool(value string) string {
+	switch strings.ToLower(value) {
+	case "", "yes", "on", "true", "1":
+		return "true"
+	case "no", "off", "false", "0":
+		return "false"
+	}
+	return value
+}
+
+// reads the flags into config, for Open and Print to use
+func ReadConfig(ctx *cli.Context) error {
+	if err := gitConfigFlags(ctx); err != nil {
+		return err
+	}
+	config.repoPath = ctx.String("repository")
+	config.displayAll = ctx.Bool("all")
+	config.reverse = ctx.Bool("reverse")
+	config.hashLen = ctx.Int("hashlength")
+	config.maxCount = ctx.Int("max-count")
+	config.skip = ctx.Int("skip")
+	config.boundary = ctx.Bool("boundary")
+	config.firstParent = ctx.Bool("first-parent")
+	config.authors = ctx.StringSlice("author")
+	config.committers = ctx.StringSlice("committer")
+	config.greps = ctx.StringSlice("grep")
+	config.invertGrep = ctx.Bool("invert-grep")
+	config.ignoreCase = ctx.Bool("regexp-ignore-case")
+	config.since, config.until = time.Time{}, time.Time{}
+	if since := ctx.String("since"); since != "" {
+		t, err := parseApproxDate(since, time.Now())
+		if err != nil {
+			return err
+		}
+		config.since = t
+	}
+	if until := ctx.String("until"); until != "" {
+		t, err := parseApproxDate(until, time.Now())
+		if err != nil {
+			return err
+		}
+		config.until = t
+	}
+	config.branchcolors = ctx.String("branchcolors")
+	config.glyphs = ctx.String("glyphs")
+	config.theme = ctx.String("theme")
+	config.noPager = ctx.Bool("no-pager")
+	config.format = ctx.String("format")
+	config.name = ctx.String("name")
+	config.timestamp = ctx.String("timestamp")
+	config.output = ctx.String("output")
+	switch config.output {
+	case "text", "json", "ndjson", "svg", "html":
+	default:
+		return fmt.Errorf("unknown --output '%s', want text, json, ndjson, svg or html", config.output)
+	}
+	switch config.name {
+	case "author", "committer", "both":
+	default:
+		return fmt.Errorf("unknown --name '%s', want author, committer or both", config.name)
+	}
+	switch config.timestamp {
+	case "author", "committer":
+	default:
+		return fmt.Errorf("unknown --timestamp '%s', want author or committer", config.timestamp)
+	}
+	dateMode, err := parseDateMode(ctx.String("date"))
+	if err != nil {
+		return err
+	}
+	config.dateMode = dateMode
+	return nil
+}
@@ -0,0 +1,45 @@
+package river
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReorderArgs(t *testing.T) {
+	tests := []struct {
+		args, want string
+	}{
+		{"", ""},
+		{"main", "main"},
+		/// options after a revision go up front
+		{"main -n 5", "-n 5 main"},
+		{"main~2 ^old --all", "--all main~2 ^old"},
+		{"a..b --format %h -r ../repo", "--format %h -r ../repo a..b"},
+		{"--max-count=5 main", "--max-count=5 main"},
+		/// bundled short options, the last one takes the value
+		{"main -ln 5", "-ln 5 main"},
+		{"main -in 5", "-in 5 main"},
+		/// a value stuck onto its short option
+		{"-n50", "-n 50"},
+		{"main -n2", "-n 2 main"},
+		{"-in50 main", "-in 50 main"},
+		{"-l12", "-l 12"},
+		{"-r../repo", "-r ../repo"},
+		/// everything from -- on is paths, a leading -- gets doubled for flag parsing to eat
+		{"-- path", "-- -- path"},
+		{"-n 3 -- -n 5", "-n 3 -- -- -n 5"},
+		{"main -- path -n 5", "main -- path -n 5"},
+		{"main -n 5 -- path", "-n 5 main -- path"},
+		/// the tui gets the options either way, see parentFlags
+		{"tui -n 3", "-n 3 tui"},
+		{"tui main -n3 -- path", "-n 3 tui main -- path"},
+	}
+	for _, test := range tests {
+		args := append([]string{"rivera"}, strings.Fields(test.args)...)
+		want := append([]string{"rivera"}, strings.Fields(test.want)...)
+		if got := ReorderArgs(args); !slices.Equal(got, want) {
+			t.Errorf("rivera %s\n got %q\nwant %q", test.args, got[1:], want[1:])
+		}
+	}
+}
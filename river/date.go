@@ -0,0 +1,247 @@
+package river
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the compact layout rivera always had, not gits "Mon Jan 2 15:04:05 2006 -0700"
+const defaultDateLayout = "2006-01-02 15:04"
+
+// what --date asked for, parsed once
+type dateMode struct {
+	kind string
+	/// strftime pattern for format:
+	pattern string
+	/// show it in our timezone instead of the committers
+	local bool
+}
+
+// takes the same names as gits --date, including the -local suffix
+func parseDateMode(mode string) (dateMode, error) {
+	if pattern, ok := strings.CutPrefix(mode, "format-local:"); ok {
+		return dateMode{kind: "format", pattern: pattern, local: true}, nil
+	}
+	if pattern, ok := strings.CutPrefix(mode, "format:"); ok {
+		return dateMode{kind: "format", pattern: pattern}, nil
+	}
+	if mode == "local" {
+		return dateMode{kind: "default", local: true}, nil
+	}
+	kind, local := strings.CutSuffix(mode, "-local")
+	switch kind {
+	case "iso8601":
+		kind = "iso"
+	case "iso8601-strict":
+		kind = "iso-strict"
+	case "rfc2822":
+		kind = "rfc"
+	case "":
+		kind = "default"
+	}
+	switch kind {
+	case "default", "relative", "iso", "iso-strict", "rfc", "short", "unix", "raw":
+		return dateMode{kind: kind, local: local}, nil
+	}
+	return dateMode{}, fmt.Errorf("unknown date format '%s'", mode)
+}
+
+func formatDate(t time.Time) string {
+	mode := config.dateMode
+	if mode.local {
+		t = t.Local()
+	}
+	switch mode.kind {
+	case "relative":
+		return relativeDate(t, time.Now())
+	case "iso":
+		return t.Format("2006-01-02 15:04:05 -0700")
+	case "iso-strict":
+		return t.Format(time.RFC3339)
+	case "rfc":
+		return t.Format("Mon, 2 Jan 2006 15:04:05 -0700")
+	case "short":
+		return t.Format("2006-01-02")
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "raw":
+		return strconv.FormatInt(t.Unix(), 10) + t.Format(" -0700")
+	case "format":
+		return strftime(t, mode.pattern)
+	}
+	return t.Format(defaultDateLayout)
+}
+
+// the common strftime conversions, unknown ones are left as they are
+func strftime(t time.Time, pattern string) string {
+	out := strings.Builder{}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i == len(pattern)-1 {
+			out.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y':
+			out.WriteString(t.Format("2006"))
+		case 'y':
+			out.WriteString(t.Format("06"))
+		case 'm':
+			out.WriteString(t.Format("01"))
+		case 'd':
+			out.WriteString(t.Format("02"))
+		case 'e':
+			out.WriteString(t.Format("_2"))
+		case 'H':
+			out.WriteString(t.Format("15"))
+		case 'I':
+			out.WriteString(t.Format("03"))
+		case 'M':
+			out.WriteString(t.Format("04"))
+		case 'S':
+			out.WriteString(t.Format("05"))
+		case 'p':
+			out.WriteString(t.Format("PM"))
+		case 'a':
+			out.WriteString(t.Format("Mon"))
+		case 'A':
+			out.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			out.WriteString(t.Format("Jan"))
+		case 'B':
+			out.WriteString(t.Format("January"))
+		case 'z':
+			out.WriteString(t.Format("-0700"))
+		case 'Z':
+			out.WriteString(t.Format("MST"))
+		case 'j':
+			out.WriteString(fmt.Sprintf("%03d", t.YearDay()))
+		case 's':
+			out.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case 'F':
+			out.WriteString(t.Format("2006-01-02"))
+		case 'T':
+			out.WriteString(t.Format("15:04:05"))
+		case 'R':
+			out.WriteString(t.Format("15:04"))
+		case 'D':
+			out.WriteString(t.Format("01/02/06"))
+		case 'c':
+			out.WriteString(t.Format("Mon Jan _2 15:04:05 2006"))
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case '%':
+			out.WriteByte('%')
+		default:
+			out.WriteByte('%')
+			out.WriteByte(pattern[i])
+		}
+	}
+	return out.String()
+}
+
+// "3 hours ago", rounded the same way as gits show_date_relative
+func relativeDate(t, now time.Time) string {
+	diff := int64(now.Sub(t) / time.Second)
+	if diff < 0 {
+		return "in the future"
+	}
+	if diff < 90 {
+		return plural(diff, "second") + " ago"
+	}
+	diff = (diff + 30) / 60
+	if diff < 90 {
+		return plural(diff, "minute") + " ago"
+	}
+	diff = (diff + 30) / 60
+	if diff < 36 {
+		return plural(diff, "hour") + " ago"
+	}
+	/// days from here on
+	diff = (diff + 12) / 24
+	if diff < 14 {
+		return plural(diff, "day") + " ago"
+	}
+	if diff < 70 {
+		return plural((diff+3)/7, "week") + " ago"
+	}
+	if diff < 365 {
+		return plural((diff+15)/30, "month") + " ago"
+	}
+	if diff < 1825 {
+		totalMonths := (diff*12*2 + 365) / (365 * 2)
+		years, months := totalMonths/12, totalMonths%12
+		if months > 0 {
+			return plural(years, "year") + ", " + plural(months, "month") + " ago"
+		}
+		return plural(years, "year") + " ago"
+	}
+	return plural((diff+183)/365, "year") + " ago"
+}
+
+func plural(n int64, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// what --since and --until take, the useful part of gits approxidate
+// dates like 2024-01-02 [15:04[:05]], @unixtime, yesterday, and "3 weeks ago" (or 3.weeks.ago)
+func parseApproxDate(text string, now time.Time) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	if seconds, ok := strings.CutPrefix(text, "@"); ok {
+		if unix, err := strconv.ParseInt(seconds, 10, 64); err == nil {
+			return time.Unix(unix, 0), nil
+		}
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	/// git takes the time of day from now when theres only a date
+	for _, layout := range []string{"2006-01-02", "2006/01/02"} {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			now = now.In(time.Local)
+			return time.Date(t.Year(), t.Month(), t.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.Local), nil
+		}
+	}
+	text = strings.ToLower(text)
+	switch text {
+	case "now", "today":
+		return now, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+	words := strings.Fields(strings.NewReplacer(".", " ", "_", " ").Replace(text))
+	if len(words) == 3 && words[2] == "ago" {
+		words = words[:2]
+	}
+	if len(words) == 2 {
+		n, err := strconv.Atoi(words[0])
+		if err == nil {
+			switch strings.TrimSuffix(words[1], "s") {
+			case "second":
+				return now.Add(-time.Duration(n) * time.Second), nil
+			case "minute":
+				return now.Add(-time.Duration(n) * time.Minute), nil
+			case "hour":
+				return now.Add(-time.Duration(n) * time.Hour), nil
+			case "day":
+				return now.AddDate(0, 0, -n), nil
+			case "week":
+				return now.AddDate(0, 0, -7*n), nil
+			case "month":
+				return now.AddDate(0, -n, 0), nil
+			case "year":
+				return now.AddDate(-n, 0, 0), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad date '%s', want something like 2024-01-02 or \"2 weeks ago\"", text)
+}
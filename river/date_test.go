@@ -0,0 +1,123 @@
+package river
+
+import (
+	"testing"
+	"time"
+)
+
+// the wants are what git prints for a commit diff seconds before GIT_TEST_DATE_NOW
+func TestRelativeDate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		diff int64
+		want string
+	}{
+		{-5, "in the future"},
+		{0, "0 seconds ago"},
+		{1, "1 second ago"},
+		{89, "89 seconds ago"},
+		{90, "2 minutes ago"},
+		{149, "2 minutes ago"},
+		{150, "3 minutes ago"},
+		{3600, "60 minutes ago"},
+		{5369, "89 minutes ago"},
+		{5370, "2 hours ago"},
+		{86400, "24 hours ago"},
+		{127769, "35 hours ago"},
+		{127770, "2 days ago"},
+		{1164569, "13 days ago"},
+		{1164570, "2 weeks ago"},
+		{6002969, "10 weeks ago"},
+		{6002970, "2 months ago"},
+		{31490969, "12 months ago"},
+		{31490970, "1 year ago"},
+		{34560000, "1 year, 1 month ago"},
+		{47520000, "1 year, 6 months ago"},
+		{157634969, "5 years ago"},
+		{157634970, "5 years ago"},
+		{173000000, "5 years ago"},
+	}
+	for _, test := range tests {
+		if got := relativeDate(now.Add(-time.Duration(test.diff)*time.Second), now); got != test.want {
+			t.Errorf("%d seconds ago got %q, want %q", test.diff, got, test.want)
+		}
+	}
+}
+
+// the wants are the --max-age `TZ=UTC git rev-parse --since=<text>` gives with GIT_TEST_DATE_NOW=1700000000
+func TestParseApproxDate(t *testing.T) {
+	defer func(saved *time.Location) { time.Local = saved }(time.Local)
+	time.Local = time.UTC
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		text string
+		want int64
+	}{
+		/// a date alone gets the time of day from now
+		{"2024-01-02", 1704233600},
+		{"2024/01/02", 1704233600},
+		{"2024-01-02 15:04", 1704207840},
+		{"2024-01-02 15:04:05", 1704207845},
+		{"2024-01-02T15:04:05", 1704207845},
+		{"2024-01-02T15:04:05+02:00", 1704200645},
+		{" 2024-01-02 ", 1704233600},
+		{"@1704067200", 1704067200},
+		{"now", 1700000000},
+		{"today", 1700000000},
+		{"yesterday", 1699913600},
+		{"10 seconds ago", 1699999990},
+		{"1 hour ago", 1699996400},
+		{"90 minutes ago", 1699994600},
+		{"5 days ago", 1699568000},
+		{"3 weeks ago", 1698185600},
+		{"3.weeks.ago", 1698185600},
+		{"3 weeks", 1698185600},
+		{"3 Weeks Ago", 1698185600},
+		{"2 months ago", 1694729600},
+		{"1 year ago", 1668464000},
+	}
+	for _, test := range tests {
+		got, err := parseApproxDate(test.text, now)
+		if err != nil {
+			t.Errorf("%q: %v", test.text, err)
+			continue
+		}
+		if got.Unix() != test.want {
+			t.Errorf("%q got %d (%s), want %d", test.text, got.Unix(), got, test.want)
+		}
+	}
+	/// git would take these as now, rivera says what it didnt understand
+	for _, text := range []string{"", "a while ago", "3 fortnights ago", "2024-13-01", "@soon"} {
+		if got, err := parseApproxDate(text, now); err == nil {
+			t.Errorf("%q got %s, want an error", text, got)
+		}
+	}
+}
+
+// the wants are what `TZ=EST5 date +<pattern>` prints for the same time
+func TestStrftime(t *testing.T) {
+	morning := time.Date(2024, 3, 4, 5, 6, 7, 0, time.FixedZone("EST", -5*60*60))
+	tests := []struct {
+		pattern, want string
+	}{
+		{"%Y|%y|%m|%d|%e", "2024|24|03|04| 4"},
+		{"%H|%I|%M|%S|%p", "05|05|06|07|AM"},
+		{"%a|%A|%b|%h|%B", "Mon|Monday|Mar|Mar|March"},
+		{"%z|%Z|%j|%s", "-0500|EST|064|1709546767"},
+		{"%F|%T|%R|%D", "2024-03-04|05:06:07|05:06|03/04/24"},
+		{"%c", "Mon Mar  4 05:06:07 2024"},
+		{"%%|%n|%t", "%|\n|\t"},
+		{"at %H:%M", "at 05:06"},
+		/// unknown conversions and a trailing % are left alone
+		{"%Q %", "%Q %"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := strftime(morning, test.pattern); got != test.want {
+			t.Errorf("strftime(%q) got %q, want %q", test.pattern, got, test.want)
+		}
+	}
+	if got := strftime(morning.Add(10*time.Hour), "%I %p"); got != "03 PM" {
+		t.Errorf("strftime(%q) in the afternoon got %q, want %q", "%I %p", got, "03 PM")
+	}
+}
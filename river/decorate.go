@@ -0,0 +1,150 @@
+package river
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// what a ref is, each kind gets its own color like gits color.decorate.<slot>
+// shown in this order next to a commit
+type refKind int
+
+const (
+	REF_HEAD refKind = iota
+	REF_TAG
+	REF_BRANCH
+	REF_REMOTE
+	REF_STASH
+)
+
+// the color.decorate slot names, and gits colors for them
+var refSlots = []string{"HEAD", "tag", "branch", "remoteBranch", "stash"}
+var refColors = []string{"bold cyan", "bold yellow", "bold green", "bold red", "bold magenta"}
+
+func (K refKind) String() string {
+	return refSlots[K]
+}
+
+// one ref next to a commit, symbolic ones have the ref they point at as target, like HEAD -> main
+type decoration struct {
+	kind         refKind
+	name, target string
+	targetKind   refKind
+}
+
+// the whole thing, uncolored
+func (D decoration) text() string {
+	if D.target == "" {
+		return D.name
+	}
+	return D.name + " -> " + D.target
+}
+
+// ref names to show next to commits, short names keyed by commit hash
+type decorations struct {
+	refs   map[plumbing.Hash][]decoration
+	styles []lipgloss.Style
+}
+
+// colors come from color.decorate.<slot> in git config, gits defaults otherwise
+func newDecorations(repo *git.Repository) *decorations {
+	D := &decorations{refs: make(map[plumbing.Hash][]decoration)}
+	/// color.decorate.branch lives in [color "decorate"]
+	settings := gitConfigSubsection(repo, "color", "decorate")
+	for kind, slot := range refSlots {
+		spec := refColors[kind]
+		if value, ok := settings[strings.ToLower(slot)]; ok {
+			spec = value
+		}
+		style, _ := parseStyle(spec)
+		D.styles = append(D.styles, style)
+	}
+	return D
+}
+
+// what kind of ref name is and how its shown, false for refs that arent HEAD, branches, remotes, tags or the stash
+func refKindOf(name plumbing.ReferenceName) (refKind, string, bool) {
+	switch {
+	case name == plumbing.HEAD:
+		return REF_HEAD, name.String(), true
+	case name.IsTag():
+		return REF_TAG, "tag: " + name.Short(), true
+	case name.IsBranch():
+		return REF_BRANCH, name.Short(), true
+	case name.IsRemote():
+		return REF_REMOTE, name.Short(), true
+	case name == "refs/stash":
+		/// git spells it out too
+		return REF_STASH, name.String(), true
+	}
+	return 0, "", false
+}
+
+// remembers name as pointing at hash, target is what a symbolic ref points at and "" otherwise
+func (D *decorations) add(hash plumbing.Hash, name, target plumbing.ReferenceName) {
+	kind, short, ok := refKindOf(name)
+	if !ok {
+		return
+	}
+	ref := decoration{kind: kind, name: short}
+	if target != "" {
+		if ref.targetKind, ref.target, ok = refKindOf(target); !ok {
+			ref.target = target.String()
+		}
+	} else if kind == REF_HEAD {
+		/// only a detached HEAD points straight at a commit
+		ref.name += " (detached)"
+	}
+	refs := D.refs[hash]
+	/// refs of a kind stay in the order they came in
+	at := len(refs)
+	for at > 0 && refs[at-1].kind > kind {
+		at--
+	}
+	D.refs[hash] = slices.Insert(refs, at, ref)
+}
+
+// the refs at hash in the order theyre shown, symbolic refs take what they point at along with them
+func (D *decorations) at(hash plumbing.Hash) []decoration {
+	refs := D.refs[hash]
+	shown := make([]decoration, 0, len(refs))
+	for _, ref := range refs {
+		pointedAt := slices.ContainsFunc(refs, func(symbolic decoration) bool {
+			return symbolic.target != "" && symbolic.target == ref.name && symbolic.targetKind == ref.kind
+		})
+		if !pointedAt {
+			shown = append(shown, ref)
+		}
+	}
+	return shown
+}
+
+// %D, colored
+func (D *decorations) list(hash plumbing.Hash) string {
+	line := ""
+	for i, ref := range D.at(hash) {
+		if i > 0 {
+			line += ", "
+		}
+		if ref.target == "" {
+			line += D.styles[ref.kind].Render(ref.name)
+			continue
+		}
+		line += D.styles[ref.kind].Render(ref.name+" -> ") + D.styles[ref.targetKind].Render(ref.target)
+	}
+	return line
+}
+
+// uncolored, for the machine readable outputs
+func (D *decorations) names(hash plumbing.Hash) []string {
+	refs := D.at(hash)
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, ref.text())
+	}
+	return names
+}
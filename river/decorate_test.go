@@ -0,0 +1,79 @@
+package river
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// the refs rivera shows next to each commit of a three commit history, after setup moved some refs around
+func TestDecorations(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, F *fixture)
+		want  map[string][]string
+	}{
+		{"attached HEAD", func(t *testing.T, F *fixture) {}, map[string][]string{
+			/// main comes along with HEAD
+			"three": {"HEAD -> main"},
+		}},
+		{"detached HEAD", func(t *testing.T, F *fixture) {
+			F.setRef(t, plumbing.NewHashReference(plumbing.HEAD, F.hashes["two"]))
+		}, map[string][]string{
+			"two":   {"HEAD (detached)"},
+			"three": {"main"},
+		}},
+		{"tag and branch", func(t *testing.T, F *fixture) {
+			F.branch(t, "topic", "two")
+			F.tag(t, "v1", "two")
+			F.branch(t, "zzz", "two")
+		}, map[string][]string{
+			/// tags before branches, though refs/heads gets read first
+			"two":   {"tag: v1", "topic", "zzz"},
+			"three": {"HEAD -> main"},
+		}},
+		{"symbolic remote HEAD", func(t *testing.T, F *fixture) {
+			F.setRef(t, plumbing.NewHashReference("refs/remotes/origin/main", F.hashes["two"]))
+			F.setRef(t, plumbing.NewHashReference("refs/remotes/origin/topic", F.hashes["two"]))
+			F.setRef(t, plumbing.NewSymbolicReference("refs/remotes/origin/HEAD", "refs/remotes/origin/main"))
+		}, map[string][]string{
+			"two":   {"origin/HEAD -> origin/main", "origin/topic"},
+			"three": {"HEAD -> main"},
+		}},
+		{"branch named like a remote branch", func(t *testing.T, F *fixture) {
+			F.branch(t, "origin/main", "two")
+			F.setRef(t, plumbing.NewHashReference("refs/remotes/origin/main", F.hashes["two"]))
+			F.setRef(t, plumbing.NewSymbolicReference("refs/remotes/origin/HEAD", "refs/remotes/origin/main"))
+		}, map[string][]string{
+			/// only the remote one is what origin/HEAD points at
+			"two":   {"origin/main", "origin/HEAD -> origin/main"},
+			"three": {"HEAD -> main"},
+		}},
+		{"HEAD on a tagged branch", func(t *testing.T, F *fixture) {
+			F.tag(t, "v2", "three")
+			F.setRef(t, plumbing.NewHashReference("refs/remotes/origin/main", F.hashes["three"]))
+		}, map[string][]string{
+			"three": {"HEAD -> main", "tag: v2", "origin/main"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			F := newFixture(t, []fixtureCommit{
+				{name: "one"},
+				{name: "two", parents: []string{"one"}},
+				{name: "three", parents: []string{"two"}},
+			}, nil)
+			test.setup(t, F)
+			F.open(t, []string{"--all"}, func(r *River) error {
+				for _, name := range []string{"one", "two", "three"} {
+					got := r.Refs(F.hashes[name])
+					if want := test.want[name]; !slices.Equal(got, want) {
+						t.Errorf("%s got %q, want %q", name, got, want)
+					}
+				}
+				return nil
+			})
+		})
+	}
+}
@@ -0,0 +1,448 @@
+package river
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+)
+
+// --author, --committer, --grep, --since and --until
+// several --author (or --committer, --grep) match when any of them does, different kinds all have to match
+type commitFilter struct {
+	authors, committers, greps []*regexp.Regexp
+	invertGrep                 bool
+	/// zero when not given
+	since, until time.Time
+}
+
+// nil when theres nothing to filter on
+func newCommitFilter() (*commitFilter, error) {
+	if len(config.authors)+len(config.committers)+len(config.greps) == 0 && config.since.IsZero() && config.until.IsZero() {
+		return nil, nil
+	}
+	F := &commitFilter{invertGrep: config.invertGrep, since: config.since, until: config.until}
+	var err error
+	if F.authors, err = compilePatterns("author", config.authors); err != nil {
+		return nil, err
+	}
+	if F.committers, err = compilePatterns("committer", config.committers); err != nil {
+		return nil, err
+	}
+	if F.greps, err = compilePatterns("grep", config.greps); err != nil {
+		return nil, err
+	}
+	return F, nil
+}
+
+// git matches a line at a time, so ^ and $ go by lines
+func compilePatterns(flag string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		/// checked as given, so the error shows what was typed
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("bad --%s pattern: %w", flag, err)
+		}
+		flags := "(?m)"
+		if config.ignoreCase {
+			flags = "(?mi)"
+		}
+		compiled = append(compiled, regexp.MustCompile(flags+pattern))
+	}
+	return compiled, nil
+}
+
+// git stops walking at commits older than --since, so does rivera
+func (F *commitFilter) tooOld(node commitgraph.CommitNode) bool {
+	return !F.since.IsZero() && node.CommitTime().Before(F.since)
+}
+
+func (F *commitFilter) match(node commitgraph.CommitNode) (bool, error) {
+	if !F.until.IsZero() && node.CommitTime().After(F.until) {
+		return false, nil
+	}
+	if len(F.authors)+len(F.committers)+len(F.greps) == 0 {
+		return true, nil
+	}
+	c, err := node.Commit()
+	if err != nil {
+		return false, err
+	}
+	if len(F.authors) > 0 && !matchAny(F.authors, ident(c.Author)) {
+		return false, nil
+	}
+	if len(F.committers) > 0 && !matchAny(F.committers, ident(c.Committer)) {
+		return false, nil
+	}
+	if len(F.greps) > 0 && matchAny(F.greps, c.Message) == F.invertGrep {
+		return false, nil
+	}
+	return true, nil
+}
+
+func matchAny(patterns []*regexp.Regexp, text string) bool {
+	return slices.ContainsFunc(patterns, func(re *regexp.Regexp) bool { return re.MatchString(text) })
+}
+
+// what git matches --author and --committer against
+func ident(signature object.Signature) string {
+	return signature.Name + " <" + signature.Email + ">"
+}
+
+// what the walk makes of one commit
+type decision struct {
+	shown bool
+	/// hidden for not touching the paths, those only lead on through one parent like in git
+	treesame bool
+	/// indexes of the parents the walk follows
+	parents []int
+}
+
+// decides what the walk follows and what gets shown, for paths and the commit filters
+// everything hidden still gets walked through, its children get linked past it, see filterWalker
+type walkFilter struct {
+	paths   *pathFilter
+	commits *commitFilter
+	/// bottoms are the commits excluded by name, git counts those as relevant anyway
+	uninteresting, bottoms map[plumbing.Hash]bool
+	decisions              map[plumbing.Hash]*decision
+	/// the same commits get asked for over and over, and without a commit-graph each one is a decode
+	nodes map[plumbing.Hash]*prunedNode
+	/// hidden commit -> the nearest shown commits below it
+	rewritten map[plumbing.Hash][]commitgraph.CommitNode
+	/// --first-parent, only the first parent counts for simplifying and rewriting
+	firstParent bool
+}
+
+func newWalkFilter(paths *pathFilter, commits *commitFilter, uninteresting map[plumbing.Hash]bool, bottoms []plumbing.Hash, firstParent bool) *walkFilter {
+	if uninteresting == nil {
+		uninteresting = make(map[plumbing.Hash]bool)
+	}
+	F := &walkFilter{
+		paths:         paths,
+		commits:       commits,
+		uninteresting: uninteresting,
+		bottoms:       make(map[plumbing.Hash]bool, len(bottoms)),
+		decisions:     make(map[plumbing.Hash]*decision),
+		nodes:         make(map[plumbing.Hash]*prunedNode),
+		rewritten:     make(map[plumbing.Hash][]commitgraph.CommitNode),
+		firstParent:   firstParent,
+	}
+	for _, hash := range bottoms {
+		F.bottoms[hash] = true
+	}
+	return F
+}
+
+// same as gits relevant_commit
+func (F *walkFilter) relevant(hash plumbing.Hash) bool {
+	return !F.uninteresting[hash] || F.bottoms[hash]
+}
+
+func (F *walkFilter) decide(node commitgraph.CommitNode) (*decision, error) {
+	if d, ok := F.decisions[node.ID()]; ok {
+		return d, nil
+	}
+	var d *decision
+	var err error
+	switch {
+	case F.commits != nil && F.commits.tooOld(node):
+		d = &decision{}
+	case F.paths != nil:
+		if d, err = F.paths.simplify(node, F.relevant, F.firstParent); err != nil {
+			return nil, err
+		}
+	default:
+		d = &decision{shown: true}
+		for i := range node.ParentHashes() {
+			d.parents = append(d.parents, i)
+		}
+	}
+	if d.shown && F.commits != nil {
+		if d.shown, err = F.commits.match(node); err != nil {
+			return nil, err
+		}
+	}
+	F.decisions[node.ID()] = d
+	return d, nil
+}
+
+func (F *walkFilter) prune(node commitgraph.CommitNode) (*prunedNode, error) {
+	if pruned, ok := node.(*prunedNode); ok {
+		return pruned, nil
+	}
+	if pruned, ok := F.nodes[node.ID()]; ok {
+		return pruned, nil
+	}
+	d, err := F.decide(node)
+	if err != nil {
+		return nil, err
+	}
+	hashes := node.ParentHashes()
+	parents := make([]plumbing.Hash, len(d.parents))
+	for i, parent := range d.parents {
+		parents[i] = hashes[parent]
+	}
+	pruned := &prunedNode{CommitNode: node, filter: F, decision: d, parents: parents}
+	F.nodes[node.ID()] = pruned
+	return pruned, nil
+}
+
+func (F *walkFilter) pruneAll(nodes []commitgraph.CommitNode) ([]commitgraph.CommitNode, error) {
+	pruned := make([]commitgraph.CommitNode, 0, len(nodes))
+	for _, node := range nodes {
+		p, err := F.prune(node)
+		if err != nil {
+			return nil, err
+		}
+		pruned = append(pruned, p)
+	}
+	return pruned, nil
+}
+
+// which parents a hidden commit leads on through, only the first with --first-parent
+// TREESAME ones follow gits one_relevant_parent, a merge only leads somewhere if just one of its parents is relevant,
+// the ones the commit filters hid lead everywhere so the river stays connected
+func (F *walkFilter) leadsTo(node *prunedNode) []int {
+	if F.firstParent && node.NumParents() > 0 {
+		return []int{0}
+	}
+	if !node.decision.treesame || node.NumParents() == 1 {
+		next := make([]int, node.NumParents())
+		for i := range next {
+			next[i] = i
+		}
+		return next
+	}
+	relevant := -1
+	for i, hash := range node.ParentHashes() {
+		if F.relevant(hash) {
+			if relevant >= 0 {
+				return nil
+			}
+			relevant = i
+		}
+	}
+	if relevant < 0 {
+		return nil
+	}
+	return []int{relevant}
+}
+
+// the walk only goes down the parents the filter follows
+type prunedNode struct {
+	commitgraph.CommitNode
+	filter   *walkFilter
+	decision *decision
+	parents  []plumbing.Hash
+}
+
+// what the commit had before the filter got to it
+func (N *prunedNode) OriginalParents() int { return N.CommitNode.NumParents() }
+
+func (N *prunedNode) NumParents() int               { return len(N.parents) }
+func (N *prunedNode) ParentHashes() []plumbing.Hash { return N.parents }
+func (N *prunedNode) ParentNode(i int) (commitgraph.CommitNode, error) {
+	if i < 0 || i >= len(N.parents) {
+		return nil, object.ErrParentNotFound
+	}
+	if parent, ok := N.filter.nodes[N.parents[i]]; ok {
+		return parent, nil
+	}
+	parent, err := N.CommitNode.ParentNode(N.decision.parents[i])
+	if err != nil {
+		return nil, err
+	}
+	return N.filter.prune(parent)
+}
+
+// a shown commit with its parents pointed past the hidden ones
+type rewrittenNode struct {
+	commitgraph.CommitNode
+	parents []commitgraph.CommitNode
+	hashes  []plumbing.Hash
+}
+
+func (N *rewrittenNode) OriginalParents() int { return originalParents(N.CommitNode) }
+
+func (N *rewrittenNode) NumParents() int               { return len(N.parents) }
+func (N *rewrittenNode) ParentHashes() []plumbing.Hash { return N.hashes }
+func (N *rewrittenNode) ParentNode(i int) (commitgraph.CommitNode, error) {
+	if i < 0 || i >= len(N.parents) {
+		return nil, object.ErrParentNotFound
+	}
+	return N.parents[i], nil
+}
+
+func (N *rewrittenNode) addParent(parent commitgraph.CommitNode) {
+	/// both sides of a merge can end up at the same commit
+	if !slices.Contains(N.hashes, parent.ID()) {
+		N.parents = append(N.parents, parent)
+		N.hashes = append(N.hashes, parent.ID())
+	}
+}
+
+// sits between the topo walk and the graph, drops the hidden commits
+// and rewrites parents to the nearest shown ancestors, so the graph never sees a lane to nowhere
+type filterWalker struct {
+	walker *topoWalker
+	filter *walkFilter
+}
+
+func (W *filterWalker) Next() (commitgraph.CommitNode, error) {
+	for {
+		node, err := W.walker.Next()
+		if err != nil {
+			return nil, err
+		}
+		pruned := node.(*prunedNode)
+		if !pruned.decision.shown {
+			continue
+		}
+		rewritten := &rewrittenNode{CommitNode: pruned}
+		for i := range pruned.ParentHashes() {
+			parent, err := pruned.ParentNode(i)
+			if err != nil {
+				return nil, err
+			}
+			/// like gits rewrite_parents, --first-parent leaves the rest as they are
+			if W.filter.firstParent && i > 0 {
+				rewritten.addParent(parent)
+				continue
+			}
+			below, err := W.shownBelow(parent.(*prunedNode))
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range below {
+				rewritten.addParent(p)
+			}
+		}
+		/// path limiting alone keeps gits parents, redundant ones and all
+		/// --first-parent only draws the first one, the rest just mark it as a merge
+		if W.filter.commits != nil && !W.filter.firstParent && len(rewritten.parents) > 1 {
+			if err := W.dropRedundant(rewritten); err != nil {
+				return nil, err
+			}
+		}
+		return rewritten, nil
+	}
+}
+
+// like gits rewrite_one, the nearest shown commits at or below node
+// empty when every line runs out before getting to one
+func (W *filterWalker) shownBelow(node *prunedNode) ([]commitgraph.CommitNode, error) {
+	var chain []plumbing.Hash
+	var found []commitgraph.CommitNode
+	for {
+		/// uninteresting commits stay as they are, the graph leaves them out on its own
+		if node.decision.shown || W.filter.uninteresting[node.ID()] {
+			found = []commitgraph.CommitNode{node}
+			break
+		}
+		if below, ok := W.filter.rewritten[node.ID()]; ok {
+			found = below
+			break
+		}
+		chain = append(chain, node.ID())
+		next := W.filter.leadsTo(node)
+		/// long runs of hidden commits go round the loop, only merges recurse
+		if len(next) == 1 {
+			parent, err := node.ParentNode(next[0])
+			if err != nil {
+				return nil, err
+			}
+			node = parent.(*prunedNode)
+			continue
+		}
+		for _, i := range next {
+			parent, err := node.ParentNode(i)
+			if err != nil {
+				return nil, err
+			}
+			below, err := W.shownBelow(parent.(*prunedNode))
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range below {
+				if !slices.ContainsFunc(found, func(f commitgraph.CommitNode) bool { return f.ID() == p.ID() }) {
+					found = append(found, p)
+				}
+			}
+		}
+		break
+	}
+	for _, hash := range chain {
+		W.filter.rewritten[hash] = found
+	}
+	return found, nil
+}
+
+// a hidden merge leads to everything shown below it, some of which the others lead to anyway
+func (W *filterWalker) dropRedundant(node *rewrittenNode) error {
+	var parents []commitgraph.CommitNode
+	var hashes []plumbing.Hash
+	for i, parent := range node.parents {
+		redundant := false
+		for j, other := range node.parents {
+			if i == j {
+				continue
+			}
+			reaches, err := W.reaches(other, parent)
+			if err != nil {
+				return err
+			}
+			if reaches {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			parents = append(parents, parent)
+			hashes = append(hashes, parent.ID())
+		}
+	}
+	node.parents, node.hashes = parents, hashes
+	return nil
+}
+
+// whether to is an ancestor of from, along what the walk follows
+// gives up below to's generation and commit time, a skewed date just leaves an extra lane
+func (W *filterWalker) reaches(from, to commitgraph.CommitNode) (bool, error) {
+	seen := map[plumbing.Hash]bool{from.ID(): true}
+	stack := []commitgraph.CommitNode{from}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for i, hash := range node.ParentHashes() {
+			if hash == to.ID() {
+				return true, nil
+			}
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			parent, err := node.ParentNode(i)
+			if err != nil {
+				return false, err
+			}
+			if nodeLevel(parent) < nodeLevel(to) || parent.CommitTime().Before(to.CommitTime()) {
+				continue
+			}
+			stack = append(stack, parent)
+		}
+	}
+	return false, nil
+}
+
+func (W *filterWalker) ForEach(cb func(commitgraph.CommitNode) error) error {
+	return forEachNode(W, cb)
+}
+
+func (W *filterWalker) Close() {
+	W.walker.Close()
+}
@@ -0,0 +1,57 @@
+package river
+
+import "testing"
+
+// `git log --graph --all`
+//	* fix7
+//	* add6
+//	*   merge5
+//	|\
+//	| * side3
+//	* | fix4
+//	* | fix2
+//	|/
+//	* add1
+
+// three authors, one commit an hour from 2024-01-01T01:00:00Z, the message is the name
+// the wants show what `git log --format=%s` shows with the same filters,
+// the parents are the nearest shown ones where git keeps the hidden ones and draws no lane to them
+var filterCommits = []fixtureCommit{
+	{name: "add1", author: "Alice Smith", touch: []string{"f"}},
+	{name: "fix2", parents: []string{"add1"}, author: "Bob Jones", touch: []string{"f"}},
+	{name: "side3", parents: []string{"add1"}, author: "Carol White", touch: []string{"g"}},
+	{name: "fix4", parents: []string{"fix2"}, author: "Alice Smith", touch: []string{"g"}},
+	{name: "merge5", parents: []string{"fix4", "side3"}, author: "Bob Jones", touch: []string{"g"}},
+	{name: "add6", parents: []string{"merge5"}, author: "Carol White", touch: []string{"f"}},
+	{name: "fix7", parents: []string{"add6"}, author: "Alice Smith", touch: []string{"g"}},
+}
+
+func TestFilter(t *testing.T) {
+	F := newFixture(t, filterCommits, map[string]string{"side": "side3"})
+	runWalkTests(t, F, []walkTest{
+		{[]string{"--author=Alice"}, []string{"fix7 fix4", "fix4 add1", "add1"}},
+		/// the email matches too
+		{[]string{"--author=alice"}, []string{"fix7 fix4", "fix4 add1", "add1"}},
+		{[]string{"-i", "--author=ALICE"}, []string{"fix7 fix4", "fix4 add1", "add1"}},
+		{[]string{"--author=Bob", "--author=Carol"}, []string{"add6 merge5", "merge5 fix2 side3", "side3", "fix2"}},
+		{[]string{"--committer=^Bob"}, []string{"merge5 fix2", "fix2"}},
+		{[]string{"--grep=^fix"}, []string{"fix7 fix4", "fix4 fix2", "fix2"}},
+		{[]string{"--grep=FIX", "-i"}, []string{"fix7 fix4", "fix4 fix2", "fix2"}},
+		{[]string{"--grep=fix", "--invert-grep"}, []string{"add6 merge5", "merge5 side3", "side3 add1", "add1"}},
+		{[]string{"--grep=fix", "--grep=merge"}, []string{"fix7 merge5", "merge5 fix4", "fix4 fix2", "fix2"}},
+		{[]string{"--author=Alice", "--grep=add"}, []string{"add1"}},
+		{[]string{"--author=Nobody"}, nil},
+		{[]string{"--since=2024-01-01T03:30:00Z"}, []string{"fix7 add6", "add6 merge5", "merge5 fix4", "fix4"}},
+		{[]string{"--until=2024-01-01T03:30:00Z"}, []string{"side3 add1", "fix2 add1", "add1"}},
+		{[]string{"--since=2024-01-01T02:30:00Z", "--until=2024-01-01T05:30:00Z"}, []string{"merge5 fix4 side3", "side3", "fix4"}},
+		{[]string{"--until=@1704074400"}, []string{"fix2 add1", "add1"}},
+		{[]string{"--author=Carol", "--all"}, []string{"add6 side3", "side3"}},
+		{[]string{"--author=Alice", "--first-parent"}, []string{"fix7 fix4", "fix4 add1", "add1"}},
+		{[]string{"--author=Bob", "--", "f"}, []string{"fix2"}},
+		{[]string{"--grep=fix", "-n", "2"}, []string{"fix7 fix4", "fix4 fix2"}},
+		/// side3 is left out of the range, uninteresting parents stay as they are and the graph drops them
+		{[]string{"--author=Alice", "side..main"}, []string{"fix7 fix4 side3", "fix4 add1"}},
+		/// git puts merge5 on the boundary, add6s parent before rewriting
+		{[]string{"--author=Carol", "--boundary", "side..main"}, []string{"add6 side3", "side3 add1"}},
+	})
+}
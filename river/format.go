@@ -0,0 +1,311 @@
+package river
+
+import (
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const defaultFormat = "%C(5)%h%C(reset) %C(4)%rd%C(reset) %R %C(3)%rn%C(reset)%d %s"
+
+const (
+	FORMAT_LITERAL = iota
+	FORMAT_PLACEHOLDER
+	FORMAT_COLOR
+	FORMAT_GRAPH
+)
+
+// like gits --pretty=format, plus %R for where the river goes
+// and %rn %rd %rr for the name and date picked with --name and --timestamp
+// without a %R the river comes first, same as git
+type commitFormat []formatPart
+type formatPart struct {
+	kind int
+	/// literal text, placeholder name or color spec
+	text string
+}
+
+// named colors git understands, lipgloss only knows numbers and hex
+var colorNames = map[string]string{
+	"black":   "0",
+	"red":     "1",
+	"green":   "2",
+	"yellow":  "3",
+	"blue":    "4",
+	"magenta": "5",
+	"cyan":    "6",
+	"white":   "7",
+}
+
+// the longest ones go first so %an doesnt get read as %a + n
+var placeholders = []string{
+	"an", "ae", "ad", "ar",
+	"cn", "ce", "cd", "cr",
+	"rn", "rd", "rr",
+	"H", "h", "P", "p", "s", "b", "d", "D", "n", "R",
+}
+
+func parseFormat(format string) commitFormat {
+	F := make(commitFormat, 0, 16)
+	literal := strings.Builder{}
+	flush := func() {
+		if literal.Len() > 0 {
+			F = append(F, formatPart{kind: FORMAT_LITERAL, text: literal.String()})
+			literal.Reset()
+		}
+	}
+	for len(format) > 0 {
+		if format[0] != '%' || len(format) == 1 {
+			literal.WriteByte(format[0])
+			format = format[1:]
+			continue
+		}
+		rest := format[1:]
+		if rest[0] == '%' {
+			literal.WriteByte('%')
+			format = rest[1:]
+			continue
+		}
+		if color, length, ok := parseColorDirective(rest); ok {
+			flush()
+			F = append(F, formatPart{kind: FORMAT_COLOR, text: color})
+			format = rest[length:]
+			continue
+		}
+		known := false
+		for _, placeholder := range placeholders {
+			if strings.HasPrefix(rest, placeholder) {
+				flush()
+				if placeholder == "R" {
+					F = append(F, formatPart{kind: FORMAT_GRAPH})
+				} else {
+					F = append(F, formatPart{kind: FORMAT_PLACEHOLDER, text: placeholder})
+				}
+				format = rest[len(placeholder):]
+				known = true
+				break
+			}
+		}
+		/// git leaves unknown placeholders alone
+		if !known {
+			literal.WriteByte('%')
+			format = rest
+		}
+	}
+	flush()
+	return F
+}
+
+// %C(spec), %Creset, %Cred, %Cgreen and %Cblue
+// returns the spec and how much of the input it used
+func parseColorDirective(rest string) (string, int, bool) {
+	if !strings.HasPrefix(rest, "C") {
+		return "", 0, false
+	}
+	if strings.HasPrefix(rest, "C(") {
+		end := strings.IndexByte(rest, ')')
+		if end < 0 {
+			return "", 0, false
+		}
+		return rest[2:end], end + 1, true
+	}
+	for _, name := range []string{"reset", "red", "green", "blue"} {
+		if strings.HasPrefix(rest[1:], name) {
+			return name, len(name) + 1, true
+		}
+	}
+	return "", 0, false
+}
+
+// renders everything before the river and everything after it
+// the part after can span several lines (%b, %n), the part before is kept to one
+func (F commitFormat) render(c *object.Commit, decor *decorations) (string, []string) {
+	before, after := strings.Builder{}, strings.Builder{}
+	out := &before
+	hasGraph := false
+	for _, part := range F {
+		if part.kind == FORMAT_GRAPH {
+			hasGraph = true
+			break
+		}
+	}
+	if !hasGraph {
+		out = &after
+	}
+
+	style := lipgloss.NewStyle()
+	styled := false
+	for _, part := range F {
+		switch part.kind {
+		case FORMAT_LITERAL:
+			out.WriteString(applyStyle(style, styled, part.text))
+		case FORMAT_COLOR:
+			style, styled = parseStyle(part.text)
+		case FORMAT_GRAPH:
+			out = &after
+		case FORMAT_PLACEHOLDER:
+			/// decorations bring their own colors
+			if part.text == "d" || part.text == "D" {
+				out.WriteString(expandPlaceholder(part.text, c, decor))
+			} else {
+				out.WriteString(applyStyle(style, styled, expandPlaceholder(part.text, c, decor)))
+			}
+		}
+	}
+	prefix := strings.ReplaceAll(before.String(), "\n", " ")
+	return prefix, strings.Split(after.String(), "\n")
+}
+
+// lipgloss would pad multi-line text into a block, so each line gets styled on its own
+func applyStyle(style lipgloss.Style, styled bool, text string) string {
+	if !styled || text == "" {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = style.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// takes a git style color spec like "bold red", "#ff00ff", "5 black" or "reset"
+// the first color is the foreground, the second the background
+func parseStyle(spec string) (lipgloss.Style, bool) {
+	style := lipgloss.NewStyle()
+	styled := false
+	colors := 0
+	for _, word := range strings.Fields(spec) {
+		switch word {
+		case "reset", "normal":
+			return lipgloss.NewStyle(), false
+		case "bold":
+			style = style.Bold(true)
+		case "dim":
+			style = style.Faint(true)
+		case "italic":
+			style = style.Italic(true)
+		case "ul", "underline":
+			style = style.Underline(true)
+		case "reverse":
+			style = style.Reverse(true)
+		case "strike":
+			style = style.Strikethrough(true)
+		default:
+			if name, ok := colorNames[word]; ok {
+				word = name
+			}
+			if colors == 0 {
+				style = style.Foreground(lipgloss.Color(word))
+			} else {
+				style = style.Background(lipgloss.Color(word))
+			}
+			colors++
+		}
+		styled = true
+	}
+	return style, styled
+}
+
+func expandPlaceholder(placeholder string, c *object.Commit, decor *decorations) string {
+	switch placeholder {
+	case "H":
+		return c.Hash.String()
+	case "h":
+		return abbrev(c.Hash)
+	case "P", "p":
+		parents := make([]string, 0, len(c.ParentHashes))
+		for _, parent := range c.ParentHashes {
+			if placeholder == "P" {
+				parents = append(parents, parent.String())
+			} else {
+				parents = append(parents, abbrev(parent))
+			}
+		}
+		return strings.Join(parents, " ")
+	case "an":
+		return c.Author.Name
+	case "ae":
+		return c.Author.Email
+	case "ad":
+		return formatDate(c.Author.When)
+	case "ar":
+		return relativeDate(c.Author.When, time.Now())
+	case "cn":
+		return c.Committer.Name
+	case "ce":
+		return c.Committer.Email
+	case "cd":
+		return formatDate(c.Committer.When)
+	case "cr":
+		return relativeDate(c.Committer.When, time.Now())
+	case "s":
+		subject, _ := splitMessage(c.Message)
+		return subject
+	case "b":
+		_, body := splitMessage(c.Message)
+		return body
+	case "d":
+		refs := decor.list(c.Hash)
+		if refs == "" {
+			return ""
+		}
+		return colorize(" (", "4") + refs + colorize(")", "4")
+	case "D":
+		return decor.list(c.Hash)
+	case "rn":
+		return shownName(c)
+	case "rd":
+		return formatDate(shownSignature(c, config.timestamp).When)
+	case "rr":
+		return relativeDate(shownSignature(c, config.timestamp).When, time.Now())
+	case "n":
+		return "\n"
+	}
+	return ""
+}
+
+// rebased and cherry-picked commits have a different author and committer,
+// both shows "alice (committed by bob)" when that happens
+func shownName(c *object.Commit) string {
+	switch config.name {
+	case "committer":
+		return c.Committer.Name
+	case "both":
+		if c.Committer.Name != c.Author.Name {
+			return c.Author.Name + " (committed by " + c.Committer.Name + ")"
+		}
+	}
+	return c.Author.Name
+}
+
+func shownSignature(c *object.Commit, who string) object.Signature {
+	if who == "committer" {
+		return c.Committer
+	}
+	return c.Author
+}
+
+func abbrev(hash plumbing.Hash) string {
+	full := hash.String()
+	if config.hashLen > 0 && config.hashLen < len(full) {
+		return full[:config.hashLen]
+	}
+	return full
+}
+
+// subject is the first paragraph on one line, body is everything after it
+func splitMessage(message string) (string, string) {
+	message = strings.Trim(message, "\n")
+	subject, body, _ := strings.Cut(message, "\n\n")
+	lines := strings.Split(subject, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	subject = strings.Join(lines, " ")
+	return subject, strings.Trim(body, "\n")
+}
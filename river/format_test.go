@@ -0,0 +1,136 @@
+package river
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestParseFormat(t *testing.T) {
+	literal := func(text string) formatPart { return formatPart{kind: FORMAT_LITERAL, text: text} }
+	placeholder := func(text string) formatPart { return formatPart{kind: FORMAT_PLACEHOLDER, text: text} }
+	color := func(text string) formatPart { return formatPart{kind: FORMAT_COLOR, text: text} }
+	river := formatPart{kind: FORMAT_GRAPH}
+	tests := []struct {
+		format string
+		want   commitFormat
+	}{
+		{"", commitFormat{}},
+		{"plain text", commitFormat{literal("plain text")}},
+		{"%h %s", commitFormat{placeholder("h"), literal(" "), placeholder("s")}},
+		/// the longest placeholder wins
+		{"%an<%ae>", commitFormat{placeholder("an"), literal("<"), placeholder("ae"), literal(">")}},
+		{"%rn %rd %rr", commitFormat{placeholder("rn"), literal(" "), placeholder("rd"), literal(" "), placeholder("rr")}},
+		{"%h%R%s", commitFormat{placeholder("h"), river, placeholder("s")}},
+		{"%s%n%b", commitFormat{placeholder("s"), placeholder("n"), placeholder("b")}},
+		{"100%%", commitFormat{literal("100%")}},
+		/// git leaves unknown placeholders and a trailing % alone
+		{"%x %q", commitFormat{literal("%x %q")}},
+		{"%a", commitFormat{literal("%a")}},
+		{"50%", commitFormat{literal("50%")}},
+		{"%C(bold red)%h%C(reset)", commitFormat{color("bold red"), placeholder("h"), color("reset")}},
+		{"%Cred%s%Creset", commitFormat{color("red"), placeholder("s"), color("reset")}},
+		{"%Cgreen%Cblue", commitFormat{color("green"), color("blue")}},
+		/// an unterminated %C( is just text
+		{"%C(red", commitFormat{literal("%C(red")}},
+		{"%Cpurple", commitFormat{literal("%Cpurple")}},
+	}
+	for _, test := range tests {
+		if got := parseFormat(test.format); !slices.Equal(got, test.want) {
+			t.Errorf("parseFormat(%q)\n got %v\nwant %v", test.format, got, test.want)
+		}
+	}
+}
+
+func TestExpandPlaceholder(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.hashLen = 7
+	config.dateMode = dateMode{kind: "iso"}
+	zone := time.FixedZone("", -5*60*60)
+	c := &object.Commit{
+		Hash:         plumbing.NewHash("0123456789abcdef0123456789abcdef01234567"),
+		Author:       object.Signature{Name: "Alice Smith", Email: "alice@example.com", When: time.Date(2024, 3, 4, 5, 6, 7, 0, zone)},
+		Committer:    object.Signature{Name: "Bob Jones", Email: "bob@example.com", When: time.Date(2024, 3, 5, 6, 7, 8, 0, time.UTC)},
+		Message:      "\nfirst line\n  wrapped subject\n\nbody one\nbody two\n\n",
+		ParentHashes: []plumbing.Hash{plumbing.NewHash("89abcdef0123456789abcdef0123456789abcdef"), plumbing.NewHash("fedcba9876543210fedcba9876543210fedcba98")},
+	}
+	tests := []struct {
+		placeholder, name, want string
+	}{
+		{"H", "", "0123456789abcdef0123456789abcdef01234567"},
+		{"h", "", "0123456"},
+		{"P", "", "89abcdef0123456789abcdef0123456789abcdef fedcba9876543210fedcba9876543210fedcba98"},
+		{"p", "", "89abcde fedcba9"},
+		{"an", "", "Alice Smith"},
+		{"ae", "", "alice@example.com"},
+		{"ad", "", "2024-03-04 05:06:07 -0500"},
+		{"cn", "", "Bob Jones"},
+		{"ce", "", "bob@example.com"},
+		{"cd", "", "2024-03-05 06:07:08 +0000"},
+		/// the subject is the first paragraph joined up, the body whats after it
+		{"s", "", "first line wrapped subject"},
+		{"b", "", "body one\nbody two"},
+		{"n", "", "\n"},
+		{"rn", "author", "Alice Smith"},
+		{"rn", "committer", "Bob Jones"},
+		{"rn", "both", "Alice Smith (committed by Bob Jones)"},
+	}
+	for _, test := range tests {
+		config.name = test.name
+		if got := expandPlaceholder(test.placeholder, c, nil); got != test.want {
+			t.Errorf("%%%s with --name=%s got %q, want %q", test.placeholder, test.name, got, test.want)
+		}
+	}
+	/// the whole hash once hashlength is too long or not set
+	for _, hashLen := range []int{0, 40, 64} {
+		config.hashLen = hashLen
+		if got := expandPlaceholder("h", c, nil); got != c.Hash.String() {
+			t.Errorf("%%h with --hashlength=%d got %q", hashLen, got)
+		}
+	}
+}
+
+// the lines under the commit row line up with wherever its river starts, however wide the prefix is
+func TestFormatPadding(t *testing.T) {
+	F := newFixture(t, []fixtureCommit{
+		{name: "one", author: "Al"},
+		{name: "two", parents: []string{"one"}, author: "Barbara", message: "two\n\nfirst\nsecond\n"},
+		{name: "three", parents: []string{"one"}, author: "Al"},
+		{name: "four", parents: []string{"two", "three"}, author: "Barbara", message: "four\n\nmerged\n"},
+	}, nil)
+	var got []string
+	F.open(t, []string{"--format", "%an %R%s%n%b"}, func(r *River) error {
+		for {
+			_, lines, _, err := r.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			for _, line := range lines {
+				got = append(got, strings.TrimRight(line, " "))
+			}
+		}
+	})
+	want := []string{
+		"Barbara *   four",
+		"        |\\  merged",
+		"Al | * three",
+		"   | |",
+		"Barbara * | two",
+		"        |/  first",
+		"        |   second",
+		"Al R one",
+		"",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
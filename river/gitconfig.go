@@ -0,0 +1,68 @@
+package river
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+)
+
+// reads section.key the way git does, repo config first, then ~/.gitconfig, then the system one
+// go-gits ConfigScoped doesnt merge the raw sections, so we walk them ourselves
+func gitConfigValue(repo *git.Repository, section, key string) (string, bool) {
+	for _, raw := range gitConfigs(repo) {
+		if s := raw.Section(section); s.HasOption(key) {
+			return s.Option(key), true
+		}
+	}
+	return "", false
+}
+
+// every value of a multi-valued section.key, all from the most specific config that has it
+func gitConfigValues(repo *git.Repository, section, key string) []string {
+	for _, raw := range gitConfigs(repo) {
+		if s := raw.Section(section); s.HasOption(key) {
+			return s.OptionAll(key)
+		}
+	}
+	return nil
+}
+
+// every key in [section "subsection"], each one from the most specific config that has it
+func gitConfigSubsection(repo *git.Repository, section, subsection string) map[string]string {
+	values := make(map[string]string)
+	for _, raw := range gitConfigs(repo) {
+		if !raw.Section(section).HasSubsection(subsection) {
+			continue
+		}
+		found := make(map[string]string)
+		/// the last one in a file wins, same as git
+		for _, option := range raw.Section(section).Subsection(subsection).Options {
+			found[strings.ToLower(option.Key)] = option.Value
+		}
+		for key, value := range found {
+			if _, ok := values[key]; !ok {
+				values[key] = value
+			}
+		}
+	}
+	return values
+}
+
+// raw configs, most specific first, broken or missing files are skipped
+// without a repo theres only the global and system ones
+func gitConfigs(repo *git.Repository) []*format.Config {
+	raws := make([]*format.Config, 0, 3)
+	if repo != nil {
+		if local, err := repo.Config(); err == nil && local.Raw != nil {
+			raws = append(raws, local.Raw)
+		}
+	}
+	for _, scope := range []gitconfig.Scope{gitconfig.GlobalScope, gitconfig.SystemScope} {
+		if cfg, err := gitconfig.LoadConfig(scope); err == nil && cfg.Raw != nil {
+			raws = append(raws, cfg.Raw)
+		}
+	}
+	return raws
+}
@@ -0,0 +1,123 @@
+package river
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+
+	"rivera/graph"
+)
+
+// writes lines out as soon as theyre handed over
+type lineWriter struct {
+	out io.Writer
+}
+
+func (W *lineWriter) WriteLine(line string) error {
+	_, err := fmt.Fprintln(W.out, line)
+	return err
+}
+
+// the reader went away (quit the pager, `| head`), not worth complaining about
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE)
+}
+
+// what --output json and ndjson emit for each row
+type jsonRow struct {
+	Kind    string     `json:"kind"`
+	Commit  string     `json:"commit"`
+	Parents []string   `json:"parents,omitempty"`
+	Refs    []string   `json:"refs,omitempty"`
+	Author  string     `json:"author,omitempty"`
+	Date    string     `json:"date,omitempty"`
+	Subject string     `json:"subject,omitempty"`
+	Width   int        `json:"width"`
+	Cells   []jsonCell `json:"cells"`
+}
+type jsonCell struct {
+	Kind   string `json:"kind"`
+	Glyph  string `json:"glyph"`
+	Column int    `json:"column"`
+	Color  int    `json:"color"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// parents, refs and the commit details only go on the commit row
+func newJSONRow(row graph.Row, decor *decorations) jsonRow {
+	record := jsonRow{
+		Kind:   row.Kind.String(),
+		Commit: row.Node.ID().String(),
+		Width:  row.Width,
+		Cells:  make([]jsonCell, 0, len(row.Cells)),
+	}
+	for _, cell := range row.Cells {
+		jc := jsonCell{Kind: cell.Kind.String(), Glyph: cell.Glyph, Column: cell.Column, Color: cell.Color}
+		if !cell.Commit.IsZero() {
+			jc.Commit = cell.Commit.String()
+		}
+		record.Cells = append(record.Cells, jc)
+	}
+	if row.Kind == graph.GRAPH_COMMIT {
+		c := rowCommit(row)
+		/// the parents the river draws, rewritten ones when limited to paths
+		parents := row.Node.ParentHashes()
+		record.Parents = make([]string, 0, len(parents))
+		for _, parent := range parents {
+			record.Parents = append(record.Parents, parent.String())
+		}
+		record.Refs = decor.names(c.Hash)
+		record.Author = c.Author.Name
+		record.Date = c.Author.When.Format(time.RFC3339)
+		record.Subject, _ = splitMessage(c.Message)
+	}
+	return record
+}
+
+// --output json and ndjson, one record per row
+type jsonRenderer struct {
+	out   *lineWriter
+	decor *decorations
+}
+
+func (J *jsonRenderer) RenderRow(row graph.Row) error {
+	record, err := json.Marshal(newJSONRow(row, J.decor))
+	if err != nil {
+		return err
+	}
+	return J.out.WriteLine(string(record))
+}
+
+// wraps one json record per write into an array, so reversing still works
+type jsonArrayWriter struct {
+	out     io.Writer
+	started bool
+}
+
+func (W *jsonArrayWriter) Write(record []byte) (int, error) {
+	sep := ",\n"
+	if !W.started {
+		sep = "[\n"
+		W.started = true
+	}
+	if _, err := io.WriteString(W.out, sep); err != nil {
+		return 0, err
+	}
+	if _, err := W.out.Write(bytes.TrimRight(record, "\n")); err != nil {
+		return 0, err
+	}
+	return len(record), nil
+}
+
+func (W *jsonArrayWriter) Close() error {
+	end := "\n]\n"
+	if !W.started {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(W.out, end)
+	return err
+}
@@ -0,0 +1,66 @@
+package river
+
+import (
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// same fallback as git when nothing is configured
+const defaultPager = "less -FRX"
+
+type pager struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+}
+
+// starts the pager git would pick: $GIT_PAGER, core.pager, $PAGER, then less
+// returns nil when paging is turned off, an empty pager or "cat" count as off too
+func startPager(repo *git.Repository) (*pager, error) {
+	command, ok := os.LookupEnv("GIT_PAGER")
+	if !ok {
+		command, ok = gitConfigValue(repo, "core", "pager")
+	}
+	if !ok {
+		command, ok = os.LookupEnv("PAGER")
+	}
+	if !ok {
+		command = defaultPager
+	}
+	if command == "" || command == "cat" {
+		return nil, nil
+	}
+
+	/// git hands it to the shell too, so `less -S` and friends work
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if _, ok := os.LookupEnv("LV"); !ok {
+		cmd.Env = append(cmd.Env, "LV=-c")
+	}
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &pager{cmd: cmd, in: in}, nil
+}
+
+// closing its input lets the pager finish, then we wait for the user to quit it
+func (P *pager) Close() error {
+	P.in.Close()
+	return P.cmd.Wait()
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,142 @@
+package river
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+)
+
+// splits `revs -- paths` apart, ReorderArgs makes sure the -- gets this far
+func splitPaths(args []string) (revs, paths []string) {
+	if i := slices.Index(args, "--"); i >= 0 {
+		return args[:i], args[i+1:]
+	}
+	return args, nil
+}
+
+// paths are relative to the top of the repository, "" is the whole tree
+func cleanPaths(paths []string) ([]string, error) {
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		clean := path.Clean(filepath.ToSlash(p))
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("path '%s' is outside the repository", p)
+		}
+		if clean == "." {
+			clean = ""
+		}
+		if !slices.Contains(cleaned, clean) {
+			cleaned = append(cleaned, clean)
+		}
+	}
+	return cleaned, nil
+}
+
+// `rivera -- paths`, the default history simplification of `git log -- paths`
+// commits that dont change anything under the paths are TREESAME and get walked through without being shown,
+// a merge thats TREESAME to one of its parents only follows that parent,
+// so side branches that got merged without touching the paths drop out with it
+type pathFilter struct {
+	paths   []string
+	entries map[plumbing.Hash][]pathEntry
+}
+
+// what sits at one of the paths, zero when nothing does
+type pathEntry struct {
+	hash plumbing.Hash
+	mode filemode.FileMode
+}
+
+func newPathFilter(paths []string) *pathFilter {
+	return &pathFilter{paths: paths, entries: make(map[plumbing.Hash][]pathEntry)}
+}
+
+func (F *pathFilter) entriesOf(node commitgraph.CommitNode) ([]pathEntry, error) {
+	if entries, ok := F.entries[node.ID()]; ok {
+		return entries, nil
+	}
+	tree, err := node.Tree()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]pathEntry, len(F.paths))
+	for i, p := range F.paths {
+		if p == "" {
+			entries[i] = pathEntry{tree.Hash, filemode.Dir}
+			continue
+		}
+		entry, err := tree.FindEntry(p)
+		switch {
+		case err == nil:
+			entries[i] = pathEntry{entry.Hash, entry.Mode}
+		/// a file where a directory should be ends up as an object that isnt a tree
+		case errors.Is(err, object.ErrEntryNotFound), errors.Is(err, object.ErrDirectoryNotFound), errors.Is(err, plumbing.ErrObjectNotFound):
+		default:
+			return nil, err
+		}
+	}
+	F.entries[node.ID()] = entries
+	return entries, nil
+}
+
+// same rules as gits try_to_simplify_commit
+// uninteresting parents dont get a say in whether a merge changed anything unless relevant says so,
+// with firstParent only the first one does
+func (F *pathFilter) simplify(node commitgraph.CommitNode, relevant func(plumbing.Hash) bool, firstParent bool) (*decision, error) {
+	entries, err := F.entriesOf(node)
+	if err != nil {
+		return nil, err
+	}
+	d := &decision{}
+	if node.NumParents() == 0 {
+		/// roots show up when they have anything under the paths at all
+		d.shown = slices.ContainsFunc(entries, func(entry pathEntry) bool { return !entry.hash.IsZero() })
+		d.treesame = !d.shown
+		return d, nil
+	}
+	relevantParents, relevantChange, irrelevantChange := 0, false, false
+	for i, hash := range node.ParentHashes() {
+		if firstParent && i > 0 {
+			break
+		}
+		parent, err := node.ParentNode(i)
+		if err != nil {
+			return nil, err
+		}
+		parentEntries, err := F.entriesOf(parent)
+		if err != nil {
+			return nil, err
+		}
+		same := slices.Equal(entries, parentEntries)
+		if !relevant(hash) {
+			irrelevantChange = irrelevantChange || !same
+			continue
+		}
+		relevantParents++
+		if same {
+			/// everything came from this side, the rest of the merge doesnt matter
+			d.treesame = true
+			d.parents = []int{i}
+			return d, nil
+		}
+		relevantChange = true
+	}
+	if relevantParents > 0 {
+		d.shown = relevantChange
+	} else {
+		d.shown = irrelevantChange
+	}
+	d.treesame = !d.shown
+	for i := range node.ParentHashes() {
+		d.parents = append(d.parents, i)
+	}
+	return d, nil
+}
@@ -0,0 +1,57 @@
+package river
+
+import "testing"
+
+// `git log --graph --all`
+//	* p8
+//	*   p7
+//	|\
+//	| * s
+//	* | p6
+//	|/
+//	*   pm
+//	|\
+//	| * p5
+//	| * p3
+//	* | p4
+//	* | p2
+//	|/
+//	* p1
+
+// two sides that each touch f, g and dir/x, merged by pm which changes f itself,
+// then s off pm merged back by p7 without anything from it
+// the wants are `git log --format='%s %P' -- paths` on the same commits, %P being the rewritten parents
+var pathCommits = []fixtureCommit{
+	{name: "p1", touch: []string{"f", "g", "dir/x"}},
+	{name: "p2", parents: []string{"p1"}, touch: []string{"g"}},
+	{name: "p3", parents: []string{"p1"}, touch: []string{"f"}},
+	{name: "p4", parents: []string{"p2"}, touch: []string{"dir/x"}},
+	{name: "p5", parents: []string{"p3"}, touch: []string{"g"}},
+	{name: "pm", parents: []string{"p4", "p5"}, touch: []string{"f"}},
+	{name: "s", parents: []string{"pm"}, touch: []string{"g"}},
+	{name: "p6", parents: []string{"pm"}, touch: []string{"dir/x"}},
+	{name: "p7", parents: []string{"p6", "s"}},
+	{name: "p8", parents: []string{"p7"}, touch: []string{"f"}},
+}
+
+var pathBranches = map[string]string{"side": "s", "old": "p4"}
+
+func TestPaths(t *testing.T) {
+	F := newFixture(t, pathCommits, pathBranches)
+	runWalkTests(t, F, []walkTest{
+		{[]string{"--", "f"}, []string{"p8 pm", "pm p1 p3", "p3 p1", "p1"}},
+		{[]string{"--", "g"}, []string{"p2 p1", "p1"}},
+		{[]string{"--", "dir"}, []string{"p6 p4", "p4 p1", "p1"}},
+		{[]string{"--", "dir/x"}, []string{"p6 p4", "p4 p1", "p1"}},
+		{[]string{"--", "f", "g"}, []string{"p8 pm", "pm p2 p5", "p5 p3", "p3 p1", "p2 p1", "p1"}},
+		{[]string{"--", "h"}, nil},
+		{[]string{"--", "."}, []string{"p8 p6", "p6 pm", "pm p4 p5", "p5 p3", "p3 p1", "p4 p2", "p2 p1", "p1"}},
+		{[]string{"--first-parent", "--", "f"}, []string{"p8 pm", "pm p1 p5", "p1"}},
+		{[]string{"--first-parent", "--", "g"}, []string{"p2 p1", "p1"}},
+		{[]string{"old..main", "--", "f"}, []string{"p8 pm", "pm p4 p3", "p3 p1"}},
+		{[]string{"old..main", "--", "g"}, nil},
+		{[]string{"--boundary", "old..main", "--", "f"}, []string{"p8 pm", "pm p4 p3", "p3 p1", "p1", "p4 p2"}},
+		{[]string{"--all", "--", "g"}, []string{"s p2", "p2 p1", "p1"}},
+		{[]string{"side", "--", "dir"}, []string{"p4 p1", "p1"}},
+	})
+}
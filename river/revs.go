@@ -0,0 +1,170 @@
+package river
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// turns rev-list style arguments into tips to walk from and tips to stop at
+// supports `a..b`, `a...b`, `^a` and anything ResolveRevision understands
+func parseRevisions(repo *git.Repository, args []string) (include, exclude []plumbing.Hash, err error) {
+	for _, arg := range args {
+		switch {
+		case strings.Contains(arg, "..."):
+			from, to, _ := strings.Cut(arg, "...")
+			left, err := resolveRevision(repo, from)
+			if err != nil {
+				return nil, nil, err
+			}
+			right, err := resolveRevision(repo, to)
+			if err != nil {
+				return nil, nil, err
+			}
+			leftCommit, err := repo.CommitObject(left)
+			if err != nil {
+				return nil, nil, err
+			}
+			rightCommit, err := repo.CommitObject(right)
+			if err != nil {
+				return nil, nil, err
+			}
+			bases, err := leftCommit.MergeBase(rightCommit)
+			if err != nil {
+				return nil, nil, err
+			}
+			include = append(include, left, right)
+			for _, base := range bases {
+				exclude = append(exclude, base.Hash)
+			}
+		case strings.Contains(arg, ".."):
+			from, to, _ := strings.Cut(arg, "..")
+			left, err := resolveRevision(repo, from)
+			if err != nil {
+				return nil, nil, err
+			}
+			right, err := resolveRevision(repo, to)
+			if err != nil {
+				return nil, nil, err
+			}
+			include = append(include, right)
+			exclude = append(exclude, left)
+		case strings.HasPrefix(arg, "^"):
+			hash, err := resolveRevision(repo, arg[1:])
+			if err != nil {
+				return nil, nil, err
+			}
+			exclude = append(exclude, hash)
+		default:
+			hash, err := resolveRevision(repo, arg)
+			if err != nil {
+				return nil, nil, err
+			}
+			include = append(include, hash)
+		}
+	}
+	return include, exclude, nil
+}
+
+// an empty side of a range means HEAD, same as git
+// go-git tries a hash prefix before refs, git goes the other way, so the part before any ~ or ^ gets looked up here
+func resolveRevision(repo *git.Repository, rev string) (plumbing.Hash, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	/// ~ and ^ cant be in a ref name
+	base, suffix := rev, ""
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		base, suffix = rev[:i], rev[i:]
+	}
+	start, err := resolveBase(repo, base)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("bad revision '%s': %w", rev, err)
+	}
+	/// go-git takes a full hash as is, and peels tags and walks the suffix from there
+	hash, err := repo.ResolveRevision(plumbing.Revision(start.String() + suffix))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("bad revision '%s': %w", rev, err)
+	}
+	return *hash, nil
+}
+
+// gits order: a full hash, then refs the way plumbing.RefRevParseRules spells them out, then a hash prefix
+func resolveBase(repo *git.Repository, name string) (plumbing.Hash, error) {
+	if len(name) == len(plumbing.ZeroHash.String()) && isHex(name) {
+		return plumbing.NewHash(name), nil
+	}
+	for _, rule := range plumbing.RefRevParseRules {
+		ref, err := storer.ResolveReference(repo.Storer, plumbing.ReferenceName(fmt.Sprintf(rule, name)))
+		if err == nil {
+			return ref.Hash(), nil
+		}
+	}
+	return resolvePrefix(repo, name)
+}
+
+// git wont take fewer than 4 hex digits as a hash
+const minPrefix = 4
+
+// the one object whose hash starts with prefix
+func resolvePrefix(repo *git.Repository, prefix string) (plumbing.Hash, error) {
+	if len(prefix) < minPrefix || !isHex(prefix) {
+		return plumbing.ZeroHash, plumbing.ErrReferenceNotFound
+	}
+	prefix = strings.ToLower(prefix)
+	/// only whole bytes can be looked up, an odd digit gets checked after
+	whole, err := hex.DecodeString(prefix[:len(prefix)&^1])
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	var candidates []plumbing.Hash
+	if fast, ok := repo.Storer.(interface {
+		HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error)
+	}); ok {
+		if candidates, err = fast.HashesWithPrefix(whole); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	} else {
+		objects, err := repo.Storer.IterEncodedObjects(plumbing.AnyObject)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		err = objects.ForEach(func(obj plumbing.EncodedObject) error {
+			if hash := obj.Hash(); bytes.HasPrefix(hash[:], whole) {
+				candidates = append(candidates, hash)
+			}
+			return nil
+		})
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+	var found []plumbing.Hash
+	for _, hash := range candidates {
+		if strings.HasPrefix(hash.String(), prefix) && !slices.Contains(found, hash) {
+			found = append(found, hash)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return plumbing.ZeroHash, plumbing.ErrReferenceNotFound
+	case 1:
+		return found[0], nil
+	}
+	return plumbing.ZeroHash, fmt.Errorf("short hash '%s' is ambiguous, %d objects start with it", prefix, len(found))
+}
+
+func isHex(text string) bool {
+	for _, r := range text {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
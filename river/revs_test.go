@@ -0,0 +1,86 @@
+package river
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// the walk fixture plus a branch named like a short hash, an annotated tag,
+// and two dangling commits whose hashes start with the same 4 digits
+func TestResolveRevision(t *testing.T) {
+	F := newFixture(t, walkCommits, walkBranches)
+	b := F.hashes["b"].String()
+	F.branch(t, b[:7], "d")
+	F.tag(t, "v1", "c")
+	ambiguous := ambiguousPrefix(t, F)
+	runWalkTests(t, F, []walkTest{
+		/// refs come before hash prefixes, like in git
+		{[]string{b[:7]}, []string{"d a", "a"}},
+		{[]string{b[:8]}, []string{"b a", "a"}},
+		{[]string{b}, []string{"b a", "a"}},
+		{[]string{b[:7] + "~1"}, []string{"a"}},
+		{[]string{"v1"}, []string{"c b", "b a", "a"}},
+		{[]string{"v1~1"}, []string{"b a", "a"}},
+		/// the tag gets peeled to the commit it tags
+		{[]string{"^v1", "main"}, []string{"f m", "m c e", "e d", "d a"}},
+		{[]string{"v1..main"}, []string{"f m", "m c e", "e d", "d a"}},
+		/// options after revisions still count
+		{[]string{"main", "-n", "2"}, []string{"f m", "m c e"}},
+	})
+	for _, test := range []struct{ rev, want string }{
+		{b[:3], "bad revision"},
+		{ambiguous, "is ambiguous"},
+		{"nosuchbranch", "bad revision"},
+		{"main..nosuchbranch", "bad revision"},
+	} {
+		err := F.run(t, []string{test.rev}, func(r *River) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("rivera %s got error %v, want one saying %q", test.rev, err, test.want)
+		}
+	}
+}
+
+// writes dangling commits until two of them share their first 4 hex digits, returns those
+func ambiguousPrefix(t *testing.T, F *fixture) string {
+	t.Helper()
+	repo, err := git.PlainOpen(F.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := repo.CommitObject(F.hashes["a"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	signature := object.Signature{Name: "A U Thor", Email: "author@example.com", When: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+	for i := 0; i < 10000; i++ {
+		commit := &object.Commit{
+			Author:       signature,
+			Committer:    signature,
+			Message:      fmt.Sprintf("dangling %d\n", i),
+			TreeHash:     root.TreeHash,
+			ParentHashes: []plumbing.Hash{F.hashes["a"]},
+		}
+		obj := repo.Storer.NewEncodedObject()
+		if err := commit.Encode(obj); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := repo.Storer.SetEncodedObject(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prefix := hash.String()[:minPrefix]
+		if seen[prefix] {
+			return prefix
+		}
+		seen[prefix] = true
+	}
+	t.Fatal("no two dangling commits share a prefix")
+	return ""
+}
@@ -0,0 +1,376 @@
+package river
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"rivera/graph"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+	"github.com/urfave/cli/v2"
+)
+
+// everything needed to walk and draw the river
+type River struct {
+	repo        *git.Repository
+	commitGraph io.Closer
+	iter        commitgraph.CommitNodeIter
+	graph       *graph.Graph
+	decor       *decorations
+	format      commitFormat
+}
+
+func (R *River) Close() {
+	R.iter.Close()
+	if R.commitGraph != nil {
+		R.commitGraph.Close()
+	}
+}
+
+// walks and draws whatever the revisions and paths in ctx.Args pick out, ReadConfig has to have run
+func Open(ctx *cli.Context) (*River, error) {
+	repo, err := git.PlainOpen(config.repoPath)
+	if err != nil {
+		return nil, err
+	}
+	nodeIndex, commitGraph := commitNodeIndex(repo)
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	revs, paths := splitPaths(ctx.Args().Slice())
+	paths, err = cleanPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	starts, stops, err := parseRevisions(repo, revs)
+	if err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 || config.displayAll {
+		starts = append(starts, head.Hash())
+	}
+
+	refs, _ := repo.References()
+	defer refs.Close()
+
+	decor := newDecorations(repo)
+	refs.ForEach(func(ref *plumbing.Reference) error {
+		switch ref.Type() {
+		case plumbing.HashReference:
+			{
+				/// tags can point at tag objects, we want what they tag
+				commitHash, ok := peelToCommit(repo, ref.Hash())
+				if !ok {
+					return nil
+				}
+				name := ref.Name()
+				if config.displayAll && name != plumbing.HEAD {
+					starts = append(starts, commitHash)
+				}
+				decor.add(commitHash, name, "")
+			}
+		case plumbing.SymbolicReference:
+			{
+				/// HEAD -> main and origin/HEAD -> origin/main, what they point at gets walked on its own
+				resolved, err := repo.Reference(ref.Name(), true)
+				if err != nil {
+					return nil
+				}
+				commitHash, ok := peelToCommit(repo, resolved.Hash())
+				if !ok {
+					return nil
+				}
+				decor.add(commitHash, ref.Name(), ref.Target())
+			}
+		}
+		return nil
+	})
+
+	startNodes, err := getNodes(nodeIndex, starts)
+	if err != nil {
+		return nil, err
+	}
+	stopNodes, err := getNodes(nodeIndex, stops)
+	if err != nil {
+		return nil, err
+	}
+	var uninteresting map[plumbing.Hash]bool
+	if len(stopNodes) > 0 {
+		uninteresting, err = uninterestingCommits(startNodes, stopNodes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	commits, err := newCommitFilter()
+	if err != nil {
+		return nil, err
+	}
+	var filter *walkFilter
+	if len(paths) > 0 || commits != nil {
+		var pathFilter *pathFilter
+		if len(paths) > 0 {
+			pathFilter = newPathFilter(paths)
+		}
+		filter = newWalkFilter(pathFilter, commits, uninteresting, stops, config.firstParent)
+		if startNodes, err = filter.pruneAll(startNodes); err != nil {
+			return nil, err
+		}
+	}
+	walker := newTopoWalker(startNodes, uninteresting, config.firstParent)
+	var iter commitgraph.CommitNodeIter = walker
+	if filter != nil {
+		iter = &filterWalker{walker: walker, filter: filter}
+	}
+	/// the graph only ever sees what gets shown, so being cut off just leaves the lanes open
+	iter = &limitWalker{iter: iter, skip: config.skip, maxCount: config.maxCount}
+
+	/// now, we build the river
+	g := graph.New()
+	g.SetColors(config.branchcolors)
+	g.SetFirstParent(config.firstParent)
+	g.SetReverse(config.reverse)
+	if err := g.SetGlyphs(config.glyphs); err != nil {
+		return nil, err
+	}
+	theme, err := loadTheme(repo, config.theme)
+	if err != nil {
+		return nil, err
+	}
+	g.SetTheme(theme)
+	g.SetHead(head.Hash())
+	if config.displayAll {
+		reachable := walker.trackReachable(head.Hash())
+		g.SetUnreachable(func(hash plumbing.Hash) bool {
+			return !reachable[hash]
+		})
+	}
+	var boundary *boundaryWalker
+	if config.boundary {
+		boundary = newBoundaryWalker(iter)
+		iter = boundary
+		g.SetBoundary(boundary.IsBoundary)
+	}
+	if uninteresting != nil {
+		g.SetInterest(func(hash plumbing.Hash) bool {
+			return !uninteresting[hash] || (boundary != nil && boundary.IsParent(hash))
+		})
+	}
+	return &River{
+		repo:        repo,
+		commitGraph: commitGraph,
+		iter:        iter,
+		graph:       g,
+		decor:       decor,
+		format:      parseFormat(config.format),
+	}, nil
+}
+
+// the river in whichever --output was asked for, paged when its going to a terminal
+func (R *River) Print() error {
+	var stdout io.Writer = os.Stdout
+	if !config.noPager && isTerminal(os.Stdout) {
+		pager, err := startPager(R.repo)
+		if err != nil {
+			return err
+		}
+		if pager != nil {
+			defer pager.Close()
+			stdout = pager.in
+		}
+	}
+	var jsonOut *jsonArrayWriter
+	if config.output == "json" {
+		jsonOut = &jsonArrayWriter{out: stdout}
+		stdout = jsonOut
+	}
+	g := R.graph
+	out := &lineWriter{out: stdout}
+	var renderer graph.Renderer
+	pic := &picture{colors: g.Colors(), decor: R.decor}
+	switch config.output {
+	case "svg", "html":
+		renderer = pic
+	case "json", "ndjson":
+		renderer = &jsonRenderer{out: out, decor: R.decor}
+	}
+	draw := func(block graph.Block) error {
+		if renderer != nil {
+			return block.Render(renderer)
+		}
+		lines, _ := commitLines(g, block, R.format, R.decor)
+		for _, line := range lines {
+			if err := out.WriteLine(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	/// reversed, the last commit walked goes first, so nothing gets drawn until the walk is done
+	var held []graph.Block
+	err := R.iter.ForEach(func(cn commitgraph.CommitNode) error {
+		c, err := cn.Commit()
+		if err != nil {
+			return err
+		}
+		block := g.Block(&commitNode{CommitNode: cn, commit: c})
+		if config.reverse {
+			held = append(held, block)
+			return nil
+		}
+		return draw(block)
+	})
+	for i := len(held) - 1; i >= 0 && err == nil; i-- {
+		err = draw(held[i])
+	}
+	if err == nil && jsonOut != nil {
+		err = jsonOut.Close()
+	}
+	if err == nil && (config.output == "svg" || config.output == "html") {
+		if config.output == "svg" {
+			err = pic.writeSVG(stdout)
+		} else {
+			err = pic.writeHTML(stdout, commitURLFor(R.repo))
+		}
+	}
+	if isBrokenPipe(err) {
+		return nil
+	}
+	return err
+}
+
+// the next commit drawn as text, for rivera-tui
+// also returns which of the lines is the commit row, io.EOF once the walk is done
+func (R *River) Next() (*object.Commit, []string, int, error) {
+	node, err := R.iter.Next()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	c, err := node.Commit()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	block := R.graph.Block(&commitNode{CommitNode: node, commit: c})
+	lines, row := commitLines(R.graph, block, R.format, R.decor)
+	return c, lines, row, nil
+}
+
+// the refs at hash, uncolored
+func (R *River) Refs(hash plumbing.Hash) []string {
+	return R.decor.names(hash)
+}
+
+// one --format placeholder for c, without the %
+func (R *River) Placeholder(placeholder string, c *object.Commit) string {
+	return expandPlaceholder(placeholder, c, R.decor)
+}
+
+// a revision like main~2 or a hash prefix
+func (R *River) Resolve(rev string) (plumbing.Hash, error) {
+	return resolveRevision(R.repo, rev)
+}
+
+// one of graph.Themes, or a [rivera-theme "name"] section from git config
+func loadTheme(repo *git.Repository, name string) (*graph.Theme, error) {
+	if theme, ok := graph.Themes[name]; ok {
+		return theme, nil
+	}
+	settings := gitConfigSubsection(repo, "rivera-theme", name)
+	if len(settings) == 0 {
+		return nil, fmt.Errorf("unknown theme '%s', want ascii, unicode, rounded or a [rivera-theme \"%s\"] section in git config", name, name)
+	}
+	theme, err := graph.LoadTheme(settings)
+	if err != nil {
+		return nil, fmt.Errorf("theme '%s': %w", name, err)
+	}
+	return theme, nil
+}
+
+// the text rows for one commit, the river with the formatted commit next to it
+// also returns which of them is the commit row
+func commitLines(g *graph.Graph, block graph.Block, format commitFormat, decor *decorations) ([]string, int) {
+	prefix, text := format.render(rowCommit(block.Rows[block.Commit]), decor)
+	/// every other row lines up under wherever the river starts
+	padding := strings.Repeat(" ", lipgloss.Width(prefix))
+	lines := make([]string, 0, max(len(block.Rows), block.Commit+len(text)))
+	for i, row := range block.Rows {
+		river := g.Line(row)
+		line := padding + river
+		if i == block.Commit {
+			line = prefix + river
+		}
+		if i >= block.Commit && len(text) > 0 {
+			line += text[0]
+			text = text[1:]
+		}
+		lines = append(lines, line)
+	}
+	/// message lines that are left over get padding rows, like git does
+	for _, t := range text {
+		lines = append(lines, padding+g.Line(block.Padding)+t)
+	}
+	return lines, block.Commit
+}
+
+// what the graph gets, it only ever looks at the hashes
+// the commit rides along for everything drawn next to the river
+type commitNode struct {
+	commitgraph.CommitNode
+	commit *object.Commit
+}
+
+func (N *commitNode) OriginalParents() int {
+	return originalParents(N.CommitNode)
+}
+
+// the parent count from before any filter rewrote them
+func originalParents(node commitgraph.CommitNode) int {
+	if rewritten, ok := node.(graph.Rewritten); ok {
+		return rewritten.OriginalParents()
+	}
+	return node.NumParents()
+}
+
+func rowCommit(row graph.Row) *object.Commit {
+	return row.Node.(*commitNode).commit
+}
+
+func getNodes(nodeIndex commitgraph.CommitNodeIndex, hashes []plumbing.Hash) ([]commitgraph.CommitNode, error) {
+	nodes := make([]commitgraph.CommitNode, 0, len(hashes))
+	for _, hash := range hashes {
+		node, err := nodeIndex.Get(hash)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// follows annotated tags down to the commit, false if theres no commit at the end
+func peelToCommit(repo *git.Repository, hash plumbing.Hash) (plumbing.Hash, bool) {
+	obj, err := repo.Object(plumbing.AnyObject, hash)
+	for err == nil {
+		switch o := obj.(type) {
+		case *object.Commit:
+			return o.Hash, true
+		case *object.Tag:
+			obj, err = o.Object()
+		default:
+			return plumbing.ZeroHash, false
+		}
+	}
+	return plumbing.ZeroHash, false
+}
+
+func colorize(text, color string) string {
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(text)
+}
@@ -0,0 +1,285 @@
+package river
+
+import (
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/urfave/cli/v2"
+)
+
+// one commit of a fixture, its message is its name unless theres a message
+// the tree is the first parents with every file in touch set to the commits name
+type fixtureCommit struct {
+	name    string
+	parents []string
+	touch   []string
+	author  string
+	message string
+}
+
+// a repository on disk with the commits in order, an hour apart, HEAD on main at the last one
+// branches point more refs/heads at commits by name
+type fixture struct {
+	dir    string
+	hashes map[string]plumbing.Hash
+	names  map[plumbing.Hash]string
+}
+
+func newFixture(t *testing.T, commits []fixtureCommit, branches map[string]string) *fixture {
+	t.Helper()
+	F := &fixture{dir: t.TempDir(), hashes: make(map[string]plumbing.Hash), names: make(map[plumbing.Hash]string)}
+	repo, err := git.PlainInit(F.dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trees := make(map[string]map[string]plumbing.Hash)
+	when := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range commits {
+		files := make(map[string]plumbing.Hash)
+		var parents []plumbing.Hash
+		for i, name := range c.parents {
+			parent, ok := F.hashes[name]
+			if !ok {
+				t.Fatalf("%s has parent %s before it", c.name, name)
+			}
+			parents = append(parents, parent)
+			if i == 0 {
+				for p, blob := range trees[name] {
+					files[p] = blob
+				}
+			}
+		}
+		for _, p := range c.touch {
+			files[p] = writeObject(t, repo, plumbing.BlobObject, []byte(c.name+"\n"))
+		}
+		author := c.author
+		if author == "" {
+			author = "A U Thor"
+		}
+		when = when.Add(time.Hour)
+		signature := object.Signature{Name: author, Email: strings.ToLower(strings.ReplaceAll(author, " ", "")) + "@example.com", When: when}
+		message := c.message
+		if message == "" {
+			message = c.name + "\n"
+		}
+		commit := &object.Commit{
+			Author:       signature,
+			Committer:    signature,
+			Message:      message,
+			TreeHash:     writeTree(t, repo, files),
+			ParentHashes: parents,
+		}
+		obj := repo.Storer.NewEncodedObject()
+		if err := commit.Encode(obj); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := repo.Storer.SetEncodedObject(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		F.hashes[c.name], F.names[hash] = hash, c.name
+		trees[c.name] = files
+	}
+	refs := map[string]string{"main": commits[len(commits)-1].name}
+	for branch, name := range branches {
+		refs[branch] = name
+	}
+	for branch, name := range refs {
+		ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), F.hashes[name])
+		if err := repo.Storer.SetReference(ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main"))
+	if err := repo.Storer.SetReference(head); err != nil {
+		t.Fatal(err)
+	}
+	return F
+}
+
+func writeObject(t *testing.T, repo *git.Repository, kind plumbing.ObjectType, content []byte) plumbing.Hash {
+	t.Helper()
+	obj := repo.Storer.NewEncodedObject()
+	obj.SetType(kind)
+	w, err := obj.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(content)
+	w.Close()
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// paths can have one directory in them, like dir/f
+func writeTree(t *testing.T, repo *git.Repository, files map[string]plumbing.Hash) plumbing.Hash {
+	t.Helper()
+	tree := &object.Tree{}
+	subtrees := make(map[string]map[string]plumbing.Hash)
+	for p, blob := range files {
+		dir, file, nested := strings.Cut(p, "/")
+		if !nested {
+			tree.Entries = append(tree.Entries, object.TreeEntry{Name: p, Mode: filemode.Regular, Hash: blob})
+			continue
+		}
+		if subtrees[dir] == nil {
+			subtrees[dir] = make(map[string]plumbing.Hash)
+		}
+		subtrees[dir][file] = blob
+	}
+	for dir, files := range subtrees {
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: writeTree(t, repo, files)})
+	}
+	/// git sorts directories as if they ended in a /
+	sortName := func(entry object.TreeEntry) string {
+		if entry.Mode == filemode.Dir {
+			return entry.Name + "/"
+		}
+		return entry.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool { return sortName(tree.Entries[i]) < sortName(tree.Entries[j]) })
+	obj := repo.Storer.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// runs rivera args on the fixture up to Open, then hands the river to use
+func (F *fixture) open(t *testing.T, args []string, use func(r *River) error) {
+	t.Helper()
+	if err := F.run(t, args, use); err != nil {
+		t.Fatalf("%s: %v", strings.Join(args, " "), err)
+	}
+}
+
+// open for when rivera is meant to fail
+func (F *fixture) run(t *testing.T, args []string, use func(r *River) error) error {
+	t.Helper()
+	/// no ~/.gitconfig [rivera] getting in the way
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	args = append([]string{"rivera", "--repository", F.dir}, args...)
+	app := &cli.App{
+		Name:                      "rivera",
+		UseShortOptionHandling:    true,
+		DisableSliceFlagSeparator: true,
+		Flags:                     Flags,
+		Action: func(ctx *cli.Context) error {
+			if err := ReadConfig(ctx); err != nil {
+				return err
+			}
+			r, err := Open(ctx)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			return use(r)
+		},
+	}
+	return app.Run(ReorderArgs(args))
+}
+
+// points refs/heads/branch at the named commit
+func (F *fixture) branch(t *testing.T, branch, name string) {
+	t.Helper()
+	F.setRef(t, plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), F.hashes[name]))
+}
+
+// an annotated tag at the named commit
+func (F *fixture) tag(t *testing.T, tag, name string) {
+	t.Helper()
+	repo, err := git.PlainOpen(F.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotated := &object.Tag{
+		Name:       tag,
+		Tagger:     object.Signature{Name: "A U Thor", Email: "author@example.com", When: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		Message:    tag + "\n",
+		TargetType: plumbing.CommitObject,
+		Target:     F.hashes[name],
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := annotated.Encode(obj); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	F.setRef(t, plumbing.NewHashReference(plumbing.NewTagReferenceName(tag), hash))
+}
+
+func (F *fixture) setRef(t *testing.T, ref *plumbing.Reference) {
+	t.Helper()
+	repo, err := git.PlainOpen(F.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Storer.SetReference(ref); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// what rivera args walks, one "commit parent parent..." per shown commit with the parents the graph gets
+func (F *fixture) walk(t *testing.T, args ...string) []string {
+	t.Helper()
+	var shown []string
+	F.open(t, args, func(r *River) error {
+		for {
+			node, err := r.iter.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			line := []string{F.names[node.ID()]}
+			for _, parent := range node.ParentHashes() {
+				line = append(line, F.name(parent))
+			}
+			shown = append(shown, strings.Join(line, " "))
+		}
+	})
+	return shown
+}
+
+func (F *fixture) name(hash plumbing.Hash) string {
+	if name, ok := F.names[hash]; ok {
+		return name
+	}
+	return hash.String()
+}
+
+type walkTest struct {
+	args []string
+	want []string
+}
+
+func runWalkTests(t *testing.T, F *fixture, tests []walkTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			got := F.walk(t, test.args...)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("rivera %s\n got %q\nwant %q", strings.Join(test.args, " "), got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package river
+
+import (
+	"fmt"
+	"html"
+	"io"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"rivera/graph"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// one character of the text river, lanes sit on the even ones
+const (
+	svgColumnWidth = 8
+	svgRowHeight   = 20
+	svgRadius      = 4
+	svgPadding     = 10
+	svgTextGap     = 16
+	svgCharWidth   = 7.5
+)
+
+// the basic 16 terminal colors, for palettes given as ansi numbers
+var ansiColors = []string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// the whole river, svg and html need the height before they can start
+type picture struct {
+	rows   []graph.Row
+	colors []string
+	decor  *decorations
+	width  int
+}
+
+func (P *picture) RenderRow(row graph.Row) error {
+	P.rows = append(P.rows, row)
+	if row.Width > P.width {
+		P.width = row.Width
+	}
+	return nil
+}
+
+func (P *picture) x(column float64) float64 {
+	return svgPadding + column*svgColumnWidth
+}
+func (P *picture) y(row int) float64 {
+	return svgPadding + float64(row)*svgRowHeight + svgRowHeight/2
+}
+
+// lipgloss takes hex and ansi numbers, svg wants css
+func cssColor(color string) string {
+	n, err := strconv.Atoi(color)
+	if err != nil {
+		return color
+	}
+	switch {
+	case n >= 0 && n < 16:
+		return ansiColors[n]
+	case n >= 16 && n < 232:
+		/// the 6x6x6 cube
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	case n >= 232 && n < 256:
+		gray := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+	return color
+}
+
+func (P *picture) color(index int) string {
+	if index < 0 || index >= len(P.colors) {
+		return "currentColor"
+	}
+	return cssColor(P.colors[index])
+}
+
+// the cell at a character column
+func (P *picture) cellAt(row, column int) (graph.Cell, bool) {
+	if row < 0 || row >= len(P.rows) || column < 0 {
+		return graph.Cell{}, false
+	}
+	for _, cell := range P.rows[row].Cells {
+		if cell.Column == column {
+			return cell, true
+		}
+	}
+	return graph.Cell{}, false
+}
+
+// whatever comes down into the top of this column, or leaves out the bottom with below
+// the color is the lane color to draw the stub through the commit with
+func (P *picture) connection(row, column int, below bool) (int, bool) {
+	next, left, right := row-1, graph.GLYPH_RMOVE, graph.GLYPH_LMOVE
+	if below {
+		next, left, right = row+1, graph.GLYPH_LMOVE, graph.GLYPH_RMOVE
+	}
+	if cell, ok := P.cellAt(next, column); ok && (cell.Kind == graph.GLYPH_PADDING || cell.Kind.IsCommit()) {
+		return cell.Color, true
+	}
+	if cell, ok := P.cellAt(next, column-1); ok && cell.Kind == left {
+		return cell.Color, true
+	}
+	if cell, ok := P.cellAt(next, column+1); ok && cell.Kind == right {
+		return cell.Color, true
+	}
+	return -1, false
+}
+
+// where a \ or / starts at the top of its row and ends at the bottom
+// a lone one moves a whole lane, but git stacks them one column apart for longer moves,
+// so those meet halfway to keep the line unbroken
+func (P *picture) slant(row, column int, kind graph.GlyphKind) (float64, float64) {
+	step := 1
+	if kind == graph.GLYPH_LMOVE {
+		step = -1
+	}
+	c := float64(column)
+	from, to := c-float64(step), c+float64(step)
+	if cell, ok := P.cellAt(row-1, column-step); ok && cell.Kind == kind {
+		from = c - float64(step)/2
+	}
+	if cell, ok := P.cellAt(row+1, column+step); ok && cell.Kind == kind {
+		to = c + float64(step)/2
+	}
+	return from, to
+}
+
+// edges as paths, commits as circles on top, no text
+func (P *picture) writeRiver(w io.Writer) {
+	circles := strings.Builder{}
+	for r, row := range P.rows {
+		y := P.y(r)
+		top, bottom := y-svgRowHeight/2, y+svgRowHeight/2
+		for _, cell := range row.Cells {
+			c := float64(cell.Column)
+			path := ""
+			switch cell.Kind {
+			case graph.GLYPH_PADDING:
+				path = fmt.Sprintf("M%g,%g L%g,%g", P.x(c), top, P.x(c), bottom)
+			case graph.GLYPH_RMOVE, graph.GLYPH_LMOVE:
+				from, to := P.slant(r, cell.Column, cell.Kind)
+				path = fmt.Sprintf("M%g,%g C%g,%g %g,%g %g,%g", P.x(from), top, P.x(from), y, P.x(to), y, P.x(to), bottom)
+			case graph.GLYPH_BRIDGE:
+				path = fmt.Sprintf("M%g,%g L%g,%g", P.x(c-1), bottom, P.x(c+1), bottom)
+			case graph.GLYPH_BRIDGE_OVER:
+				path = fmt.Sprintf("M%g,%g L%g,%g", P.x(c-1), top, P.x(c+1), top)
+			case graph.GLYPH_MULTIBRANCH_EXTENSION, graph.GLYPH_MULTIBRANCH_EXTENSION_UP:
+				path = fmt.Sprintf("M%g,%g L%g,%g", P.x(c-1), y, P.x(c+1), y)
+				/// the octopus parents drop down from the even columns, or come up with --reverse
+				end := bottom
+				if cell.Kind == graph.GLYPH_MULTIBRANCH_EXTENSION_UP {
+					end = top
+				}
+				if cell.Column%2 == 0 {
+					path += fmt.Sprintf(" M%g,%g L%g,%g", P.x(c), y, P.x(c), end)
+				}
+			case graph.GLYPH_MULTIBRANCH_START:
+				path = fmt.Sprintf("M%g,%g Q%g,%g %g,%g", P.x(c-1), y, P.x(c), y, P.x(c), bottom)
+			case graph.GLYPH_MULTIBRANCH_START_UP:
+				path = fmt.Sprintf("M%g,%g Q%g,%g %g,%g", P.x(c-1), y, P.x(c), y, P.x(c), top)
+			case graph.GLYPH_SKIP:
+				fmt.Fprintf(w, `<text x="%g" y="%g" fill="gray" text-anchor="middle">⋮</text>`+"\n", P.x(c), y+4)
+			case graph.GLYPH_COMMIT, graph.GLYPH_ROOT, graph.GLYPH_BOUNDARY, graph.GLYPH_MERGE, graph.GLYPH_HEAD, graph.GLYPH_UNREACHABLE:
+				/// the lanes run through the commit, but only if theres something to connect to
+				if color, ok := P.connection(r, cell.Column, false); ok {
+					fmt.Fprintf(w, `<path d="M%g,%g L%g,%g" stroke="%s" stroke-width="2"/>`+"\n", P.x(c), top, P.x(c), y, P.color(color))
+				}
+				if color, ok := P.connection(r, cell.Column, true); ok {
+					fmt.Fprintf(w, `<path d="M%g,%g L%g,%g" stroke="%s" stroke-width="2"/>`+"\n", P.x(c), y, P.x(c), bottom, P.color(color))
+				}
+				/// boundary commits arent part of the walk, so theyre drawn faded out
+				stroke, fill := `stroke="black" stroke-width="1.5"`, "white"
+				switch cell.Kind {
+				case graph.GLYPH_BOUNDARY:
+					stroke = `stroke="gray" stroke-width="1.5" stroke-dasharray="2,2"`
+				case graph.GLYPH_UNREACHABLE:
+					stroke = `stroke="gray" stroke-width="1.5"`
+				case graph.GLYPH_HEAD:
+					stroke = `stroke="black" stroke-width="3"`
+				/// --first-parent hides the other lanes of a merge, this still shows it
+				case graph.GLYPH_MERGE:
+					fill = "black"
+				}
+				fmt.Fprintf(&circles, `<circle cx="%g" cy="%g" r="%d" fill="%s" %s><title>%s</title></circle>`+"\n",
+					P.x(c), y, svgRadius, fill, stroke, row.Node.ID().String())
+			}
+			if path != "" {
+				fmt.Fprintf(w, `<path d="%s" stroke="%s" stroke-width="2" fill="none"/>`+"\n", path, P.color(cell.Color))
+			}
+		}
+	}
+	io.WriteString(w, circles.String())
+}
+
+// commits as circles, edges as curves, hash, refs and subject to the right
+func (P *picture) writeSVG(w io.Writer) error {
+	textX := P.x(float64(P.width)) + svgTextGap
+	width := textX + 600
+	height := 2*svgPadding + len(P.rows)*svgRowHeight
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%d" font-family="monospace" font-size="12">`+"\n", width, height)
+	P.writeRiver(w)
+	for r, row := range P.rows {
+		if row.Kind != graph.GRAPH_COMMIT {
+			continue
+		}
+		c := rowCommit(row)
+		y := P.y(r) + 4
+		x := textX
+		subject, _ := splitMessage(c.Message)
+		fmt.Fprintf(w, `<text x="%g" y="%g" fill="#8a2be2">%s</text>`+"\n", x, y, html.EscapeString(abbrev(c.Hash)))
+		x += float64(len(abbrev(c.Hash))+1) * svgCharWidth
+		for _, ref := range P.decor.at(c.Hash) {
+			text := ref.text()
+			refWidth := float64(len(text))*svgCharWidth + 6
+			fmt.Fprintf(w, `<rect x="%g" y="%g" width="%g" height="14" rx="3" fill="%s"/>`+"\n", x, y-11, refWidth, refBackgrounds[ref.kind])
+			fmt.Fprintf(w, `<text x="%g" y="%g" fill="white">%s</text>`+"\n", x+3, y, html.EscapeString(text))
+			x += refWidth + 4
+		}
+		fmt.Fprintf(w, `<text x="%g" y="%g"><title>%s</title>%s</text>`+"\n", x, y, html.EscapeString(c.Message), html.EscapeString(subject))
+	}
+	_, err := io.WriteString(w, "</svg>\n")
+	return err
+}
+
+var refBackgrounds = map[refKind]string{
+	REF_HEAD:   "#008b8b",
+	REF_TAG:    "#b8860b",
+	REF_BRANCH: "#228b22",
+	REF_REMOTE: "#b22222",
+	REF_STASH:  "#8b008b",
+}
+
+// the river as an svg next to a list of commits, with links, tooltips and ref badges
+// commitURL gets the full hash through %s
+func (P *picture) writeHTML(w io.Writer, commitURL string) error {
+	riverWidth := P.x(float64(P.width)) + svgPadding
+	height := 2*svgPadding + len(P.rows)*svgRowHeight
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>rivera</title>
+<style>
+body { font-family: monospace; font-size: 12px; margin: 0; }
+.river { position: absolute; top: 0; left: 0; }
+.rows { position: absolute; top: %dpx; left: %gpx; margin: 0; padding: 0; list-style: none; }
+.rows li { height: %dpx; line-height: %dpx; white-space: nowrap; }
+.hash { color: #8a2be2; text-decoration: none; margin-right: 6px; }
+.hash:hover { text-decoration: underline; }
+.ref { color: white; border-radius: 3px; padding: 0 4px; margin-right: 4px; background: #b22222; }
+.ref.head { background: #008b8b; }
+.ref.tag { background: #b8860b; }
+.ref.branch { background: #228b22; }
+.ref.remotebranch { background: #b22222; }
+.ref.stash { background: #8b008b; }
+.author { color: #996b00; margin-right: 6px; }
+</style>
+</head>
+<body>
+`, svgPadding, riverWidth, svgRowHeight, svgRowHeight)
+	fmt.Fprintf(w, `<svg class="river" xmlns="http://www.w3.org/2000/svg" width="%g" height="%d">`+"\n", riverWidth, height)
+	P.writeRiver(w)
+	io.WriteString(w, "</svg>\n<ul class=\"rows\">\n")
+	for _, row := range P.rows {
+		if row.Kind != graph.GRAPH_COMMIT {
+			io.WriteString(w, "<li></li>\n")
+			continue
+		}
+		c := rowCommit(row)
+		hash := c.Hash.String()
+		subject, _ := splitMessage(c.Message)
+		fmt.Fprintf(w, `<li id="%s" title="%s">`, hash, html.EscapeString(strings.TrimSpace(c.Message)))
+		fmt.Fprintf(w, `<a class="hash" href="%s" title="%s">%s</a>`, html.EscapeString(fmt.Sprintf(commitURL, hash)), hash, abbrev(c.Hash))
+		for _, ref := range P.decor.at(c.Hash) {
+			fmt.Fprintf(w, `<span class="ref %s">%s</span>`, strings.ToLower(ref.kind.String()), html.EscapeString(ref.text()))
+		}
+		fmt.Fprintf(w, `<span class="author">%s</span>%s</li>`+"\n", html.EscapeString(shownName(c)), html.EscapeString(subject))
+	}
+	_, err := io.WriteString(w, "</ul>\n</body>\n</html>\n")
+	return err
+}
+
+// links to the commit page on the forge origin points at, or just to the row itself
+// handles https://host/path.git and git@host:path.git
+func commitURLFor(repo *git.Repository) string {
+	remote, err := repo.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return "#%s"
+	}
+	url := remote.Config().URLs[0]
+	switch {
+	case strings.HasPrefix(url, "https://"), strings.HasPrefix(url, "http://"):
+		/// dont leak tokens into the page
+		parsed, err := neturl.Parse(url)
+		if err != nil {
+			return "#%s"
+		}
+		parsed.User = nil
+		url = parsed.String()
+	case strings.HasPrefix(url, "git@"):
+		host, path, ok := strings.Cut(strings.TrimPrefix(url, "git@"), ":")
+		if !ok {
+			return "#%s"
+		}
+		url = "https://" + host + "/" + path
+	default:
+		return "#%s"
+	}
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	return strings.ReplaceAll(url, "%", "%%") + "/commit/%s"
+}
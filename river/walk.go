@@ -0,0 +1,481 @@
+package river
+
+import (
+	"container/heap"
+	"io"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// topo-order walk over several tips, like `git rev-list --topo-order a b c`
+// adapted from go-gits commitNodeIteratorTopological, which only takes one start
+// children are always emitted before their parents, tips are started newest first
+type topoWalker struct {
+	explore  *nodeHeap
+	visit    []commitgraph.CommitNode
+	inCounts map[plumbing.Hash]int
+	/// tips still waiting to be emitted, false once they are
+	/// tips can sit in the visit stack twice, this keeps them from being emitted twice
+	tips   map[plumbing.Hash]bool
+	ignore map[plumbing.Hash]bool
+	/// --first-parent, merges only lead on to their first parent
+	/// the other sides still get counted, like git without a commit-graph a tip that got merged waits for the merge
+	/// so walked has what the first parents reach, the rest never gets emitted
+	firstParent bool
+	walked      map[plumbing.Hash]bool
+	/// nil unless trackReachable was called
+	reachable map[plumbing.Hash]bool
+}
+
+func newTopoWalker(starts []commitgraph.CommitNode, ignore map[plumbing.Hash]bool, firstParent bool) *topoWalker {
+	if ignore == nil {
+		ignore = make(map[plumbing.Hash]bool)
+	}
+	W := &topoWalker{
+		explore:  &nodeHeap{},
+		visit:    make([]commitgraph.CommitNode, 0, len(starts)),
+		inCounts: make(map[plumbing.Hash]int),
+		tips:     make(map[plumbing.Hash]bool, len(starts)),
+		ignore:   ignore,
+
+		firstParent: firstParent,
+	}
+	if firstParent {
+		W.walked = make(map[plumbing.Hash]bool)
+	}
+	/// oldest first, so the newest tip ends up on top of the stack
+	sorted := append(make([]commitgraph.CommitNode, 0, len(starts)), starts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CommitTime().Before(sorted[j].CommitTime())
+	})
+	for _, node := range sorted {
+		if _, dupe := W.tips[node.ID()]; dupe || ignore[node.ID()] {
+			continue
+		}
+		W.tips[node.ID()] = true
+		W.visit = append(W.visit, node)
+		heap.Push(W.explore, node)
+	}
+	return W
+}
+
+func (W *topoWalker) Next() (commitgraph.CommitNode, error) {
+	var next commitgraph.CommitNode
+	for {
+		if len(W.visit) == 0 {
+			return nil, io.EOF
+		}
+		next = W.visit[len(W.visit)-1]
+		W.visit = W.visit[:len(W.visit)-1]
+		if pending, isTip := W.tips[next.ID()]; isTip && !pending {
+			continue
+		}
+		/// every descendant of next has a higher level, so once theyre explored the count is final
+		if err := W.exploreTo(nodeLevel(next)); err != nil {
+			return nil, err
+		}
+		if W.inCounts[next.ID()] == 0 {
+			break
+		}
+	}
+
+	minimumLevel := nodeLevel(next)
+	parents := make([]commitgraph.CommitNode, len(W.parentHashes(next)))
+	for i, hash := range W.parentHashes(next) {
+		if W.ignore[hash] {
+			continue
+		}
+		parent, err := next.ParentNode(i)
+		if err != nil {
+			return nil, err
+		}
+		parents[i] = parent
+		if level := nodeLevel(parent); level < minimumLevel {
+			minimumLevel = level
+		}
+	}
+	if err := W.exploreTo(minimumLevel); err != nil {
+		return nil, err
+	}
+
+	for i, hash := range W.parentHashes(next) {
+		if parents[i] == nil {
+			continue
+		}
+		W.inCounts[hash]--
+		if W.inCounts[hash] == 0 {
+			W.visit = append(W.visit, parents[i])
+		}
+	}
+	for i, hash := range next.ParentHashes()[len(parents):] {
+		if W.ignore[hash] {
+			continue
+		}
+		W.inCounts[hash]--
+		if _, isTip := W.tips[hash]; W.inCounts[hash] > 0 || !(isTip || W.walked[hash]) {
+			continue
+		}
+		parent, err := next.ParentNode(len(parents) + i)
+		if err != nil {
+			return nil, err
+		}
+		W.visit = append(W.visit, parent)
+	}
+	delete(W.inCounts, next.ID())
+	/// children come first, so next already knows if it can be reached
+	if W.reachable[next.ID()] {
+		for _, hash := range W.parentHashes(next) {
+			W.reachable[hash] = true
+		}
+	}
+	if _, isTip := W.tips[next.ID()]; isTip {
+		W.tips[next.ID()] = false
+	}
+	return next, nil
+}
+
+// count incoming edges for everything at or above level
+func (W *topoWalker) exploreTo(level uint64) error {
+	for W.explore.Len() > 0 {
+		node := (*W.explore)[0]
+		if nodeLevel(node) < level {
+			break
+		}
+		heap.Pop(W.explore)
+		for i, hash := range node.ParentHashes() {
+			if W.ignore[hash] {
+				continue
+			}
+			W.inCounts[hash]++
+			if W.firstParent && i > 0 {
+				continue
+			}
+			/// tips went into the heap up front
+			if _, isTip := W.tips[hash]; W.reached(hash) && !isTip {
+				parent, err := node.ParentNode(i)
+				if err != nil {
+					return err
+				}
+				heap.Push(W.explore, parent)
+			}
+		}
+	}
+	return nil
+}
+
+// keeps track of what from reaches along the parents the walk follows,
+// the map fills in as the walk goes and has every commit in it by the time its emitted
+func (W *topoWalker) trackReachable(from plumbing.Hash) map[plumbing.Hash]bool {
+	W.reachable = map[plumbing.Hash]bool{from: true}
+	return W.reachable
+}
+
+// true the first time the walk goes on to hash
+func (W *topoWalker) reached(hash plumbing.Hash) bool {
+	if !W.firstParent {
+		return W.inCounts[hash] == 1
+	}
+	if W.walked[hash] {
+		return false
+	}
+	W.walked[hash] = true
+	return true
+}
+
+// the parents the walk goes on to
+func (W *topoWalker) parentHashes(node commitgraph.CommitNode) []plumbing.Hash {
+	hashes := node.ParentHashes()
+	if W.firstParent && len(hashes) > 1 {
+		return hashes[:1]
+	}
+	return hashes
+}
+
+func (W *topoWalker) ForEach(cb func(commitgraph.CommitNode) error) error {
+	return forEachNode(W, cb)
+}
+
+func (W *topoWalker) Close() {}
+
+// ForEach for all the walkers here, storer.ErrStop just stops
+func forEachNode(iter commitgraph.CommitNodeIter, cb func(commitgraph.CommitNode) error) error {
+	for {
+		node, err := iter.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := cb(node); err != nil {
+			if err == storer.ErrStop {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// --skip and --max-count, a negative maxCount means no limit
+type limitWalker struct {
+	iter                  commitgraph.CommitNodeIter
+	skip, maxCount, shown int
+}
+
+func (W *limitWalker) Next() (commitgraph.CommitNode, error) {
+	for ; W.skip > 0; W.skip-- {
+		if _, err := W.iter.Next(); err != nil {
+			return nil, err
+		}
+	}
+	if W.maxCount >= 0 && W.shown >= W.maxCount {
+		return nil, io.EOF
+	}
+	node, err := W.iter.Next()
+	if err == nil {
+		W.shown++
+	}
+	return node, err
+}
+
+func (W *limitWalker) ForEach(cb func(commitgraph.CommitNode) error) error {
+	return forEachNode(W, cb)
+}
+
+func (W *limitWalker) Close() {
+	W.iter.Close()
+}
+
+// --boundary, the parents of shown commits that dont get shown themselves
+// they come after everything else, in the order git puts them, so ranges and -n show where they were cut off
+type boundaryWalker struct {
+	iter  commitgraph.CommitNodeIter
+	shown map[plumbing.Hash]bool
+	/// parents of shown commits in the order they turned up, some get shown later
+	parents   []commitgraph.CommitNode
+	isParent  map[plumbing.Hash]bool
+	boundary  []commitgraph.CommitNode
+	finishing bool
+}
+
+func newBoundaryWalker(iter commitgraph.CommitNodeIter) *boundaryWalker {
+	return &boundaryWalker{
+		iter:     iter,
+		shown:    make(map[plumbing.Hash]bool),
+		isParent: make(map[plumbing.Hash]bool),
+	}
+}
+
+func (W *boundaryWalker) Next() (commitgraph.CommitNode, error) {
+	if !W.finishing {
+		node, err := W.iter.Next()
+		if err == io.EOF {
+			W.finishing = true
+			W.boundary = W.sortBoundary()
+		} else if err != nil {
+			return nil, err
+		} else {
+			W.shown[node.ID()] = true
+			for i, hash := range node.ParentHashes() {
+				if W.shown[hash] || W.isParent[hash] {
+					continue
+				}
+				parent, err := node.ParentNode(i)
+				if err != nil {
+					return nil, err
+				}
+				W.isParent[hash] = true
+				W.parents = append(W.parents, parent)
+			}
+			return node, nil
+		}
+	}
+	if len(W.boundary) == 0 {
+		return nil, io.EOF
+	}
+	node := W.boundary[0]
+	W.boundary = W.boundary[1:]
+	return node, nil
+}
+
+// the graph keeps lanes to these, the shown ones are interesting anyway
+func (W *boundaryWalker) IsParent(hash plumbing.Hash) bool {
+	return W.isParent[hash]
+}
+
+// only true once the walk is handing out the boundary
+func (W *boundaryWalker) IsBoundary(hash plumbing.Hash) bool {
+	return W.finishing && W.isParent[hash] && !W.shown[hash]
+}
+
+// gits create_boundary_commit_list, newest found first,
+// then sort_in_topological_order counting only the edges between boundary commits
+func (W *boundaryWalker) sortBoundary() []commitgraph.CommitNode {
+	nodes := make(map[plumbing.Hash]commitgraph.CommitNode)
+	var order []commitgraph.CommitNode
+	for i := len(W.parents) - 1; i >= 0; i-- {
+		if node := W.parents[i]; !W.shown[node.ID()] {
+			nodes[node.ID()] = node
+			order = append(order, node)
+		}
+	}
+	inCounts := make(map[plumbing.Hash]int)
+	for _, node := range order {
+		for _, hash := range node.ParentHashes() {
+			if _, ok := nodes[hash]; ok {
+				inCounts[hash]++
+			}
+		}
+	}
+	/// a stack, but the tips still come off in the order they were found
+	var stack []commitgraph.CommitNode
+	for i := len(order) - 1; i >= 0; i-- {
+		if inCounts[order[i].ID()] == 0 {
+			stack = append(stack, order[i])
+		}
+	}
+	sorted := make([]commitgraph.CommitNode, 0, len(order))
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		sorted = append(sorted, node)
+		for _, hash := range node.ParentHashes() {
+			parent, ok := nodes[hash]
+			if !ok {
+				continue
+			}
+			if inCounts[hash]--; inCounts[hash] == 0 {
+				stack = append(stack, parent)
+			}
+		}
+	}
+	return sorted
+}
+
+func (W *boundaryWalker) ForEach(cb func(commitgraph.CommitNode) error) error {
+	return forEachNode(W, cb)
+}
+
+func (W *boundaryWalker) Close() {
+	W.iter.Close()
+}
+
+// generation number, commits outside the commit-graph file report the max
+func nodeLevel(node commitgraph.CommitNode) uint64 {
+	if level := node.GenerationV2(); level != 0 {
+		return level
+	}
+	return node.Generation()
+}
+
+// max-heap on level, then commit time
+type nodeHeap []commitgraph.CommitNode
+
+func (h nodeHeap) Len() int { return len(h) }
+func (h nodeHeap) Less(i, j int) bool {
+	if li, lj := nodeLevel(h[i]), nodeLevel(h[j]); li != lj {
+		return li > lj
+	}
+	return h[i].CommitTime().After(h[j].CommitTime())
+}
+func (h nodeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *nodeHeap) Push(x any)   { *h = append(*h, x.(commitgraph.CommitNode)) }
+func (h *nodeHeap) Pop() any {
+	old := *h
+	node := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return node
+}
+
+// how many extra rounds to walk once everything queued is uninteresting
+// covers commits with skewed dates, git uses the same
+const limitSlop = 5
+
+// finds the commits reachable from exclude that matter for include, like gits limit_list
+// walks newest first and stops once every commit left in the queue is excluded,
+// so `main..feature` doesnt walk all of main
+func uninterestingCommits(include, exclude []commitgraph.CommitNode) (map[plumbing.Hash]bool, error) {
+	uninteresting := make(map[plumbing.Hash]bool)
+	seen := make(map[plumbing.Hash]commitgraph.CommitNode)
+	queue := &timeHeap{}
+	for _, node := range exclude {
+		uninteresting[node.ID()] = true
+		seen[node.ID()] = node
+		heap.Push(queue, node)
+	}
+	for _, node := range include {
+		if _, ok := seen[node.ID()]; !ok {
+			seen[node.ID()] = node
+			heap.Push(queue, node)
+		}
+	}
+
+	slop := limitSlop
+	for queue.Len() > 0 {
+		if queue.everyoneIn(uninteresting) {
+			if slop--; slop == 0 {
+				break
+			}
+		} else {
+			slop = limitSlop
+		}
+		node := heap.Pop(queue).(commitgraph.CommitNode)
+		for i, hash := range node.ParentHashes() {
+			if _, ok := seen[hash]; ok {
+				if uninteresting[node.ID()] && !uninteresting[hash] {
+					markAncestors(seen, uninteresting, hash)
+				}
+				continue
+			}
+			parent, err := node.ParentNode(i)
+			if err != nil {
+				return nil, err
+			}
+			seen[hash] = parent
+			if uninteresting[node.ID()] {
+				uninteresting[hash] = true
+			}
+			heap.Push(queue, parent)
+		}
+	}
+	return uninteresting, nil
+}
+
+// marks hash and everything already seen below it uninteresting
+func markAncestors(seen map[plumbing.Hash]commitgraph.CommitNode, uninteresting map[plumbing.Hash]bool, hash plumbing.Hash) {
+	stack := []plumbing.Hash{hash}
+	for len(stack) > 0 {
+		hash = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node, ok := seen[hash]
+		if !ok || uninteresting[hash] {
+			continue
+		}
+		uninteresting[hash] = true
+		stack = append(stack, node.ParentHashes()...)
+	}
+}
+
+// max-heap on commit time
+type timeHeap []commitgraph.CommitNode
+
+func (h timeHeap) Len() int           { return len(h) }
+func (h timeHeap) Less(i, j int) bool { return h[i].CommitTime().After(h[j].CommitTime()) }
+func (h timeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *timeHeap) Push(x any)        { *h = append(*h, x.(commitgraph.CommitNode)) }
+func (h *timeHeap) Pop() any {
+	old := *h
+	node := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return node
+}
+func (h timeHeap) everyoneIn(set map[plumbing.Hash]bool) bool {
+	for _, node := range h {
+		if !set[node.ID()] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package river
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// `git log --graph --all`
+//	* f
+//	*   m
+//	|\
+//	* | c
+//	* | b
+//	| | * t
+//	| |/
+//	| * e
+//	| * d
+//	|/
+//	* a
+
+// main is a-b-c and a-d-e merged by m, with f on top, topic branches off e
+// the wants are `git log --format='%s %P'` on the same commits
+var walkCommits = []fixtureCommit{
+	{name: "a", touch: []string{"f"}},
+	{name: "b", parents: []string{"a"}, touch: []string{"g"}},
+	{name: "d", parents: []string{"a"}, touch: []string{"h"}},
+	{name: "c", parents: []string{"b"}, touch: []string{"f"}},
+	{name: "e", parents: []string{"d"}, touch: []string{"f"}},
+	{name: "m", parents: []string{"c", "e"}},
+	{name: "t", parents: []string{"e"}, touch: []string{"h"}},
+	{name: "f", parents: []string{"m"}, touch: []string{"g"}},
+}
+
+var walkBranches = map[string]string{"topic": "t", "side": "e", "old": "c"}
+
+func TestWalk(t *testing.T) {
+	F := newFixture(t, walkCommits, walkBranches)
+	runWalkTests(t, F, []walkTest{
+		{nil, []string{"f m", "m c e", "e d", "d a", "c b", "b a", "a"}},
+		{[]string{"--all"}, []string{"f m", "m c e", "c b", "b a", "t e", "e d", "d a", "a"}},
+		{[]string{"old..main"}, []string{"f m", "m c e", "e d", "d a"}},
+		{[]string{"main...topic"}, []string{"f m", "m c e", "c b", "b a", "t e"}},
+		{[]string{"^side", "main"}, []string{"f m", "m c e", "c b", "b a"}},
+		{[]string{"--first-parent"}, []string{"f m", "m c e", "c b", "b a", "a"}},
+		{[]string{"-n", "3"}, []string{"f m", "m c e", "e d"}},
+		{[]string{"--skip", "2", "-n", "3"}, []string{"e d", "d a", "c b"}},
+		{[]string{"--boundary", "old..main"}, []string{"f m", "m c e", "e d", "d a", "a", "c b"}},
+		{[]string{"topic", "old"}, []string{"t e", "e d", "d a", "c b", "b a", "a"}},
+		{[]string{"main~1"}, []string{"m c e", "e d", "d a", "c b", "b a", "a"}},
+		{[]string{"--all", "--first-parent"}, []string{"f m", "m c e", "c b", "b a", "t e", "e d", "d a", "a"}},
+	})
+}
+
+// like a clone with --depth 3, c and e are in .git/shallow and everything below them is gone
+func TestWalkShallow(t *testing.T) {
+	F := newFixture(t, walkCommits, walkBranches)
+	shallow := F.hashes["c"].String() + "\n" + F.hashes["e"].String() + "\n"
+	if err := os.WriteFile(filepath.Join(F.dir, ".git", "shallow"), []byte(shallow), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "d"} {
+		hash := F.hashes[name].String()
+		if err := os.Remove(filepath.Join(F.dir, ".git", "objects", hash[:2], hash[2:])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runWalkTests(t, F, []walkTest{
+		{nil, []string{"f m", "m c e", "e", "c"}},
+		{[]string{"--all"}, []string{"f m", "m c e", "c", "t e", "e"}},
+	})
+}